@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/fadyZohdy/gLox/pkg/ast"
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+)
+
+// backend is one of the two engines able to run scripts, both keep their globals between runs
+type backend interface {
+	// compile runs the static passes over stmts, problems go to the reporter the backend was created with
+	compile(stmts []ast.Stmt)
+	// run executes what was compiled last and returns the value of its last expression statement
+	run() (any, error)
+}
+
+func newBackend(vm bool, reporter diagnostics.Reporter, options ...ast.Option) backend {
+	if vm {
+		return &bytecodeVM{
+			vm:       ast.NewVM(runtimeError, options...),
+			resolver: ast.NewResolver(reporter),
+			compiler: ast.NewCompiler(reporter),
+		}
+	}
+	interpreter := ast.NewInterpreter(runtimeError, options...)
+	return &treeWalker{interpreter: interpreter, resolver: ast.NewResolver(reporter)}
+}
+
+type treeWalker struct {
+	interpreter *ast.Interpreter
+	resolver    *ast.Resolver
+	stmts       []ast.Stmt
+}
+
+func (w *treeWalker) compile(stmts []ast.Stmt) {
+	w.resolver.Resolve(&stmts)
+	w.stmts = stmts
+}
+
+func (w *treeWalker) run() (any, error) {
+	return w.interpreter.Execute(w.stmts)
+}
+
+type bytecodeVM struct {
+	vm       *ast.VM
+	resolver *ast.Resolver
+	compiler *ast.Compiler
+	script   *ast.Prototype
+}
+
+func (b *bytecodeVM) compile(stmts []ast.Stmt) {
+	// the resolver only checks for errors here, the compiler does its own scoping
+	b.resolver.Resolve(&stmts)
+	b.script = b.compiler.Compile(stmts)
+}
+
+func (b *bytecodeVM) run() (any, error) {
+	return b.vm.Execute(b.script)
+}
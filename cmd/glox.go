@@ -1,19 +1,28 @@
 package main
 
 import (
-	"bufio"
-	"fmt"
+	"errors"
+	"flag"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/fadyZohdy/gLox/pkg/ast"
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
 var hadError bool
 var hadRuntimeError bool
 
+var useVM = flag.Bool("vm", false, "run on the bytecode virtual machine instead of the tree-walking interpreter")
+var modulePath = flag.String("path", "", "directories, separated by "+string(os.PathListSeparator)+", where imported modules are looked for")
+
+// file and source being run, kept around to quote the offending line in error messages
+var fileName string
+var source string
+
 func main() {
 	// f, err := os.Create("cpu.prof")
 	// if err != nil {
@@ -21,19 +30,26 @@ func main() {
 	// }
 	// pprof.StartCPUProfile(f)
 	// defer pprof.StopCPUProfile()
-	if len(os.Args) == 1 {
+	flag.Parse()
+	if flag.NArg() == 0 {
 		runPrompt()
 	} else {
-		runFile(os.Args[1])
+		runFile(flag.Arg(0))
 	}
 }
 
-func runFile(fileName string) {
-	content, err := ioutil.ReadFile(fileName)
+// searchPath passes the -path directories on to the backend
+func searchPath() ast.Option {
+	return ast.WithSearchPath(filepath.SplitList(*modulePath)...)
+}
+
+func runFile(name string) {
+	content, err := ioutil.ReadFile(name)
 	if err != nil {
-		log.Fatal("Could not open file: " + fileName)
+		log.Fatal("Could not open file: " + name)
 		return
 	}
+	fileName = name
 	run(string(content))
 	if hadError {
 		os.Exit(65)
@@ -43,62 +59,43 @@ func runFile(fileName string) {
 	}
 }
 
-func runPrompt() {
-	// TODO: support arrow keys and history
-	interpreter := ast.NewInterpreter(runtimeError)
-	for {
-		fmt.Printf("> ")
-		reader := bufio.NewReader(os.Stdin)
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			log.Fatal()
-			return
-		}
-		if len(line) == 1 {
-			continue
-		}
-		source := string(line)
-		scanner := scanner.NewScanner(source, func(line int, message string) { report(line, "", message) })
-		tokens := scanner.ScanTokens()
-		parser := ast.NewParser(tokens, report)
-		stmts, err := parser.Parse()
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		// if expr, ok := stmts[0].(*ast.Expression); ok {
-		for _, stmt := range stmts {
-			if expr, ok := stmt.(*ast.Expression); ok {
-				fmt.Println(interpreter.Evaluate(expr))
-			} else {
-				interpreter.Interpret(&[]ast.Stmt{stmt})
-			}
-			hadError = false
-		}
-	}
-}
-
-func run(source string) {
-	scanner := scanner.NewScanner(source, func(line int, message string) { report(line, "", message) })
+func run(code string) {
+	source = code
+	var collector diagnostics.Collector
+	scanner := scanner.NewScanner(source, &collector)
 	tokens := scanner.ScanTokens()
-	parser := ast.NewParser(tokens, report)
-	stmts, err := parser.Parse()
-	if err != nil {
-		log.Println(err)
+	parser := ast.NewParser(tokens, &collector)
+	stmts, _ := parser.Parse()
+	// statements that failed to parse are dropped, so checking what is left still finds valid errors
+	backend := newBackend(*useVM, &collector, ast.WithFileName(fileName), searchPath())
+	backend.compile(stmts)
+	if collector.HasErrors() {
+		report(collector.Diagnostics())
 		return
 	}
-	interpreter := ast.NewInterpreter(runtimeError)
-	resolver := ast.NewResolver(interpreter, report)
-	resolver.Resolve(&stmts)
-	interpreter.Interpret(&stmts)
+	if _, err := backend.run(); err != nil {
+		var runtimeErr *ast.RuntimeError
+		if errors.As(err, &runtimeErr) {
+			runtimeError(runtimeErr)
+		}
+	}
 }
 
 func runtimeError(err *ast.RuntimeError) {
-	log.Println(err.Message, "[line ", err.Token.Line, "]")
+	renderRuntimeError(err)
 	hadRuntimeError = true
 }
 
-func report(line int, where string, message string) {
-	log.Println("[line ", line, "] Error", where, ": ", message)
+// renderRuntimeError quotes the line err was raised at, from the imported module it was raised in if any
+func renderRuntimeError(err *ast.RuntimeError) {
+	name, code := fileName, source
+	if err.File != "" {
+		name, code = err.File, err.Source
+	}
+	diagnostics.Render(os.Stderr, name, code, err.Diagnostic())
+}
+
+func report(ds []*diagnostics.Diagnostic) {
+	diagnostics.RenderAll(os.Stderr, fileName, source, ds)
 	hadError = true
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errInterrupted is returned by readLine when ctrl-c is pressed
+var errInterrupted = errors.New("interrupted")
+
+const maxHistory = 1000
+
+// lineEditor reads lines with emacs style editing and history when stdin is a terminal,
+// otherwise it falls back to reading plain lines so piping a script into the REPL still works
+type lineEditor struct {
+	in          *os.File
+	out         io.Writer
+	reader      *bufio.Reader
+	history     []string
+	historyPath string
+}
+
+func newLineEditor(in *os.File, out io.Writer, historyPath string) *lineEditor {
+	e := &lineEditor{in: in, out: out, reader: bufio.NewReader(in), historyPath: historyPath}
+	e.loadHistory()
+	return e
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore()
+
+	line, err := e.edit(prompt)
+	if err == nil && strings.TrimSpace(line) != "" {
+		e.addHistory(line)
+	}
+	return line, err
+}
+
+func (e *lineEditor) readPlainLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// edit runs the key handling loop, the terminal must already be in raw mode
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	// history is browsed from the end, the line being typed is kept aside meanwhile
+	historyIndex := len(e.history)
+	pending := ""
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	showHistory := func(index int) {
+		if index < 0 || index > len(e.history) || index == historyIndex {
+			return
+		}
+		if historyIndex == len(e.history) {
+			pending = string(buf)
+		}
+		historyIndex = index
+		if index == len(e.history) {
+			buf = []rune(pending)
+		} else {
+			buf = []rune(e.history[index])
+		}
+		pos = len(buf)
+	}
+
+	refresh()
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // ctrl-c
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // ctrl-d
+			if len(buf) == 0 {
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // ctrl-a
+			pos = 0
+		case 5: // ctrl-e
+			pos = len(buf)
+		case 2: // ctrl-b
+			if pos > 0 {
+				pos--
+			}
+		case 6: // ctrl-f
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // ctrl-k
+			buf = buf[:pos]
+		case 21: // ctrl-u
+			buf = buf[pos:]
+			pos = 0
+		case 16: // ctrl-p
+			showHistory(historyIndex - 1)
+		case 14: // ctrl-n
+			showHistory(historyIndex + 1)
+		case 27: // escape sequences for arrows, home, end and delete
+			switch e.readEscape() {
+			case "[A", "OA":
+				showHistory(historyIndex - 1)
+			case "[B", "OB":
+				showHistory(historyIndex + 1)
+			case "[C", "OC":
+				if pos < len(buf) {
+					pos++
+				}
+			case "[D", "OD":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+			case "[F", "OF", "[4~":
+				pos = len(buf)
+			case "[3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r == '\t' || r >= ' ' {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		refresh()
+	}
+}
+
+// readEscape reads what follows an escape character up to the final byte of the sequence
+func (e *lineEditor) readEscape() string {
+	var seq strings.Builder
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return seq.String()
+		}
+		seq.WriteRune(r)
+		// the introducer is followed by parameters and ends with a letter or '~'
+		if seq.Len() > 1 && (r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')) {
+			return seq.String()
+		}
+	}
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	e.appendHistory(line)
+}
+
+func (e *lineEditor) loadHistory() {
+	if e.historyPath == "" {
+		return
+	}
+	content, err := os.ReadFile(e.historyPath)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// appendHistory saves line right away so history survives the REPL being killed
+func (e *lineEditor) appendHistory(line string) {
+	if e.historyPath == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fadyZohdy/gLox/pkg/ast"
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+// repl keeps a single backend alive between inputs so
+// globals, functions and closures defined on one line are usable on the next
+type repl struct {
+	editor  *lineEditor
+	backend backend
+	// reset before every input, the backend reports into it too
+	collector diagnostics.Collector
+}
+
+func runPrompt() {
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, ".glox_history")
+	}
+	r := &repl{editor: newLineEditor(os.Stdin, os.Stdout, historyPath)}
+	r.backend = newBackend(*useVM, &r.collector, searchPath())
+	r.loop()
+}
+
+func (r *repl) loop() {
+	for {
+		input, err := r.read()
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			// ctrl-d or the end of piped input
+			fmt.Println()
+			return
+		}
+		if strings.TrimSpace(input) != "" {
+			r.eval(input)
+		}
+	}
+}
+
+// read keeps asking for lines while the input so far is incomplete,
+// e.g. it has unclosed braces or an unterminated string.
+// an empty line submits whatever was typed so far.
+func (r *repl) read() (string, error) {
+	line, err := r.editor.readLine(prompt)
+	if err != nil {
+		return "", err
+	}
+	input := line
+	for incomplete(input) {
+		line, err := r.editor.readLine(continuationPrompt)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		input += "\n" + line
+	}
+	return input, nil
+}
+
+func (r *repl) eval(input string) {
+	fileName, source = "", input
+
+	r.collector.Reset()
+	s := scanner.NewScanner(input, &r.collector)
+	parser := ast.NewParser(s.ScanTokens(), &r.collector)
+	stmts, _ := parser.Parse()
+	// a single expression without the trailing ';' is accepted so values can be inspected quickly
+	if r.collector.HasErrors() && !strings.HasSuffix(strings.TrimSpace(input), ";") {
+		if retried, ok := parseWithSemicolon(input); ok {
+			r.collector.Reset()
+			stmts = retried
+		}
+	}
+	if !r.collector.HasErrors() {
+		r.backend.compile(stmts)
+	}
+	if r.collector.HasErrors() {
+		diagnostics.RenderAll(os.Stderr, fileName, source, r.collector.Diagnostics())
+		return
+	}
+
+	value, err := r.backend.run()
+	var runtimeErr *ast.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		renderRuntimeError(runtimeErr)
+		return
+	}
+	if _, ok := stmts[len(stmts)-1].(*ast.Expression); ok && value != nil {
+		fmt.Println(value)
+	}
+}
+
+func parseWithSemicolon(input string) ([]ast.Stmt, bool) {
+	var collector diagnostics.Collector
+	s := scanner.NewScanner(input+";", &collector)
+	stmts, _ := ast.NewParser(s.ScanTokens(), &collector).Parse()
+	return stmts, !collector.HasErrors()
+}
+
+// incomplete reports whether input has unbalanced brackets or an unterminated string or comment
+func incomplete(input string) bool {
+	var collector diagnostics.Collector
+	tokens := scanner.NewScanner(input, &collector).ScanTokens()
+	for _, d := range collector.Diagnostics() {
+		if d.Code == diagnostics.UnterminatedString || d.Code == diagnostics.UnterminatedComment {
+			return true
+		}
+	}
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case scanner.LEFT_PAREN, scanner.LEFT_BRACE, scanner.LEFT_BRACKET:
+			depth++
+		case scanner.RIGHT_PAREN, scanner.RIGHT_BRACE, scanner.RIGHT_BRACKET:
+			depth--
+		}
+	}
+	return depth > 0
+}
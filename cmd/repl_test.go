@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fadyZohdy/gLox/pkg/ast"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"print 1;", false},
+		{"fun f() {", true},
+		{"fun f() {\n  if (x) {\n  }", true},
+		{"fun f() {\n}", false},
+		{"print (1 +", true},
+		{"var xs = [1,", true},
+		{"var m = {\"a\": [1, 2]};", false},
+		// brackets inside strings and comments don't count
+		{"print \"{\";", false},
+		{"print \"}\"; {", true},
+		{"// {", false},
+		{"/* ( */ print 1;", false},
+		// an unterminated string or comment continues on the next line
+		{"print \"abc", true},
+		{"/* comment", true},
+		// extra closing brackets are a syntax error, not a reason to wait for more input
+		{"}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := incomplete(tt.input); got != tt.expected {
+				t.Errorf("incomplete(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseWithSemicolon(t *testing.T) {
+	tests := []struct {
+		input string
+		ok    bool
+		// expression is set when the input parses to a single expression statement
+		expression bool
+	}{
+		{"1 + 2", true, true},
+		{"x = 3", true, true},
+		{"f(1)", true, true},
+		{"\"a\" + \"b\"", true, true},
+		{"var x = 1", true, false},
+		{"1 +", false, false},
+		{"print", false, false},
+		{"{ 1 }", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			stmts, ok := parseWithSemicolon(tt.input)
+			if ok != tt.ok {
+				t.Fatalf("parseWithSemicolon(%q) ok = %v, want %v", tt.input, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			_, expression := stmts[len(stmts)-1].(*ast.Expression)
+			if len(stmts) != 1 || expression != tt.expression {
+				t.Errorf("parseWithSemicolon(%q) = %d statements, expression %v, want 1 statement, expression %v", tt.input, len(stmts), expression, tt.expression)
+			}
+		})
+	}
+}
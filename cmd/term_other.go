@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// line editing is only supported on linux for now, other platforms read plain lines
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw mode not supported")
+}
@@ -0,0 +1,55 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+var benchmarks = []struct {
+	name   string
+	source string
+}{
+	{"fib", `
+fun fib(n) {
+	if (n < 2) return n;
+	return fib(n - 1) + fib(n - 2);
+}
+fib(20);
+`},
+	{"loops", `
+fun sum(n) {
+	var total = 0;
+	for (var i = 0; i < n; i = i + 1) {
+		var j = 0;
+		while (j < 10) {
+			total = total + i * j;
+			j = j + 1;
+		}
+	}
+	return total;
+}
+sum(2000);
+`},
+}
+
+func BenchmarkBackends(b *testing.B) {
+	for _, bench := range benchmarks {
+		for _, backend := range backends {
+			b.Run(bench.name+"/"+backend.name, func(b *testing.B) {
+				var collector diagnostics.Collector
+				tokens := scanner.NewScanner(bench.source, &collector).ScanTokens()
+				stmts, _ := NewParser(tokens, &collector).Parse()
+				interpreter := backend.new(func(err *RuntimeError) { b.Fatal(err) })
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					interpreter.run(stmts, &collector)
+				}
+				if collector.HasErrors() {
+					b.Fatal(collector.Diagnostics()[0].Message)
+				}
+			})
+		}
+	}
+}
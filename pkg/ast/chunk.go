@@ -0,0 +1,97 @@
+package ast
+
+import "github.com/fadyZohdy/gLox/pkg/scanner"
+
+type OpCode byte
+
+// operands follow the opcode in the chunk, u8 and u16 tell their size.
+// u16 operands are stored big endian.
+const (
+	OP_CONSTANT      OpCode = iota // u16 constant index
+	OP_NIL                         //
+	OP_TRUE                        //
+	OP_FALSE                       //
+	OP_POP                         //
+	OP_PICK                        // u8 depth, pushes a copy of the value that many slots below the top
+	OP_BURY                        // u8 depth, moves the top value that many slots down
+	OP_GET_LOCAL                   // u8 slot
+	OP_SET_LOCAL                   // u8 slot
+	OP_GET_GLOBAL                  // u16 name constant
+	OP_DEFINE_GLOBAL               // u16 name constant
+	OP_DEFINE_CONST                // u16 name constant, a global that can't be assigned to
+	OP_SET_GLOBAL                  // u16 name constant
+	OP_GET_UPVALUE                 // u8 upvalue index
+	OP_SET_UPVALUE                 // u8 upvalue index
+	OP_GET_PROPERTY                // u16 name constant
+	OP_SET_PROPERTY                // u16 name constant
+	OP_GET_SUPER                   // u16 name constant
+	OP_GET_INDEX                   //
+	OP_SET_INDEX                   //
+	OP_EQUAL                       //
+	OP_GREATER                     //
+	OP_GREATER_EQUAL               //
+	OP_LESS                        //
+	OP_LESS_EQUAL                  //
+	OP_ADD                         //
+	OP_SUBTRACT                    //
+	OP_MULTIPLY                    //
+	OP_DIVIDE                      //
+	OP_MODULO                      //
+	OP_NOT                         //
+	OP_NEGATE                      //
+	OP_INCREMENT                   //
+	OP_DECREMENT                   //
+	OP_CHECK_BOOL                  //
+	OP_PRINT                       //
+	OP_JUMP                        // u16 forward offset
+	OP_JUMP_IF_FALSE               // u16 forward offset, leaves the condition on the stack
+	OP_LOOP                        // u16 backward offset
+	OP_CALL                        // u8 argument count
+	OP_CALL_ARGS                   // u8 positional count, u8 named count, then a u16 name constant per named argument
+	OP_SPREAD                      // turns the list on top of the stack into arguments for OP_CALL_ARGS
+	OP_DEFAULT                     // u8 parameter slot, u16 forward offset past its default value when an argument was passed
+	OP_CLOSURE                     // u16 prototype constant, then an (u8 isLocal, u8 index) pair per upvalue
+	OP_CLOSE_UPVALUE               //
+	OP_RETURN                      //
+	OP_CLASS                       // u16 name constant
+	OP_INHERIT                     //
+	OP_METHOD                      // u16 name constant
+	OP_STATIC_METHOD               // u16 name constant
+	OP_LIST                        // u16 element count
+	OP_MAP                         // u16 entry count
+	OP_INTERPOLATE                 // u16 part count, concatenates the stringified parts
+	OP_THROW                       //
+	OP_TRY                         // u16 forward offset to the handler, which finds the error on the stack
+	OP_END_TRY                     //
+	OP_CAUGHT                      // replaces the error on the stack with the value catch binds
+	OP_RETHROW                     //
+	OP_IMPORT                      // u16 path constant, pushes the module
+	OP_ERROR                       // u16 message constant, raises a runtime error
+)
+
+// Chunk is a sequence of bytecode instructions along with the constants they refer to
+type Chunk struct {
+	code      []byte
+	constants []any
+	// tokens[i] is the token code[i] was compiled from, runtime errors are reported at it
+	tokens []scanner.Token
+}
+
+func (c *Chunk) write(b byte, token scanner.Token) {
+	c.code = append(c.code, b)
+	c.tokens = append(c.tokens, token)
+}
+
+// addConstant returns the index of value in the constant table, reusing an existing slot for strings and numbers
+func (c *Chunk) addConstant(value any) int {
+	switch value.(type) {
+	case string, float64:
+		for i, constant := range c.constants {
+			if constant == value {
+				return i
+			}
+		}
+	}
+	c.constants = append(c.constants, value)
+	return len(c.constants) - 1
+}
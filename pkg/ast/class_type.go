@@ -0,0 +1,9 @@
+package ast
+
+type ClassType int
+
+const (
+	NO_CLASS ClassType = iota
+	CLASS
+	SUBCLASS
+)
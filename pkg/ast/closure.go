@@ -0,0 +1,127 @@
+package ast
+
+import "fmt"
+
+// Prototype is a function compiled to bytecode, the script itself compiles to a prototype without parameters
+type Prototype struct {
+	name     string
+	class    string
+	minArity int
+	maxArity int
+	// params names the parameters in their slots, which follow the receiver's
+	params       []string
+	upvalueCount int
+	functionType FunctionType
+	chunk        Chunk
+}
+
+func (p *Prototype) arity() (min, max int) {
+	return p.minArity, p.maxArity
+}
+
+func (p *Prototype) parameters() []string {
+	return p.params
+}
+
+func (p *Prototype) String() string {
+	if p.name == "" {
+		return "<fn>"
+	}
+	return "<fn " + p.name + ">"
+}
+
+// Closure is a prototype along with the variables it captured, it's what the VM calls
+type Closure struct {
+	prototype *Prototype
+	upvalues  []*upvalue
+	// module is where the closure was created, its globals are the ones the closure sees
+	module *LoxModule
+}
+
+func (c *Closure) String() string {
+	return c.prototype.String()
+}
+
+// upvalue refers to a variable still living on the VM stack until it goes out of scope,
+// it's then closed over and keeps the value itself
+type upvalue struct {
+	slot   int
+	closed bool
+	value  any
+	// open upvalues form a list sorted by decreasing slot
+	next *upvalue
+}
+
+// VMClass mirrors LoxClass for the VM. static methods and fields live on the class itself.
+type VMClass struct {
+	name          string
+	superclass    *VMClass
+	methods       map[string]*Closure
+	staticMethods map[string]*Closure
+	fields        map[string]any
+}
+
+func newVMClass(name string) *VMClass {
+	return &VMClass{
+		name:          name,
+		methods:       make(map[string]*Closure),
+		staticMethods: make(map[string]*Closure),
+		fields:        make(map[string]any),
+	}
+}
+
+func (klass *VMClass) String() string {
+	return klass.name
+}
+
+// arity and parameters are the ones of init, a class without one takes no arguments
+func (klass *VMClass) arity() (min, max int) {
+	if init := klass.findMethod("init"); init != nil {
+		return init.prototype.arity()
+	}
+	return 0, 0
+}
+
+func (klass *VMClass) parameters() []string {
+	if init := klass.findMethod("init"); init != nil {
+		return init.prototype.parameters()
+	}
+	return nil
+}
+
+func (klass *VMClass) findMethod(name string) *Closure {
+	for k := klass; k != nil; k = k.superclass {
+		if method, ok := k.methods[name]; ok {
+			return method
+		}
+	}
+	return nil
+}
+
+func (klass *VMClass) findStaticMethod(name string) *Closure {
+	for k := klass; k != nil; k = k.superclass {
+		if method, ok := k.staticMethods[name]; ok {
+			return method
+		}
+	}
+	return nil
+}
+
+type VMInstance struct {
+	class  *VMClass
+	fields map[string]any
+}
+
+func (instance *VMInstance) String() string {
+	return fmt.Sprintf("%s instance", instance.class.name)
+}
+
+// boundMethod is a method looked up on a receiver, the receiver becomes "this" when it's called
+type boundMethod struct {
+	receiver any
+	method   *Closure
+}
+
+func (b *boundMethod) String() string {
+	return b.method.String()
+}
@@ -0,0 +1,895 @@
+package ast
+
+import (
+	"math"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// Compiler turns resolved statements into bytecode for the VM.
+// like the resolver it walks the tree once, but it keeps track of stack slots
+// for locals instead of scope maps so the VM never looks locals up by name.
+type Compiler struct {
+	current  *functionCompiler
+	reporter diagnostics.Reporter
+}
+
+// functionCompiler holds the state of the function being compiled, enclosing points to the one around it
+type functionCompiler struct {
+	enclosing  *functionCompiler
+	prototype  *Prototype
+	locals     []local
+	upvalues   []upvalueRef
+	scopeDepth int
+	loops      []*loop
+	tries      []*tryBlock
+}
+
+type local struct {
+	name  string
+	depth int
+	// captured locals are moved off the stack when they go out of scope
+	captured bool
+}
+
+type upvalueRef struct {
+	index   byte
+	isLocal bool
+}
+
+// loop remembers the jumps of the break and continue statements inside it
+// so they can be patched once the end of its body and the loop itself are known
+type loop struct {
+	label      string
+	scopeDepth int
+	breaks     []int
+	continues  []int
+}
+
+// tryBlock is a try statement being compiled. returns, breaks and continues leaving it
+// have to drop its handler while it's installed and run its finally clause.
+type tryBlock struct {
+	handler bool
+	finally *Block
+	// loops is how many loops were around the try statement
+	loops int
+}
+
+func NewCompiler(reporter diagnostics.Reporter) *Compiler {
+	return &Compiler{reporter: reporter}
+}
+
+// Compile compiles a whole script. the script returns the value of its last statement
+// when it is an expression statement, which is what the REPL prints.
+func (c *Compiler) Compile(stmts []Stmt) *Prototype {
+	c.beginFunction(&Prototype{functionType: SCRIPT}, "")
+	for idx, stmt := range stmts {
+		if expr, ok := stmt.(*Expression); ok && idx == len(stmts)-1 {
+			c.expression(expr.expression)
+			c.emit(scanner.Token{}, byte(OP_RETURN))
+			return c.endFunction()
+		}
+		c.statement(stmt)
+	}
+	return c.endFunction()
+}
+
+func (c *Compiler) statement(stmt Stmt) {
+	stmt.accept(c)
+}
+
+func (c *Compiler) expression(expr Expr) {
+	expr.accept(c)
+}
+
+func (c *Compiler) VisitExpressionStmt(stmt *Expression) any {
+	c.expression(stmt.expression)
+	c.emit(scanner.Token{}, byte(OP_POP))
+	return nil
+}
+
+func (c *Compiler) VisitPrintStmt(stmt *Print) any {
+	c.expression(stmt.expression)
+	c.emit(stmt.keyword, byte(OP_PRINT))
+	return nil
+}
+
+func (c *Compiler) VisitVarStmt(stmt *Var) any {
+	if stmt.initializer != nil {
+		c.expression(stmt.initializer)
+	} else {
+		c.emit(stmt.name, byte(OP_NIL))
+	}
+	if stmt.constant && c.current.scopeDepth == 0 {
+		// locals need no checks at runtime, the resolver already rejected assignments to them
+		c.emitU16(stmt.name, OP_DEFINE_CONST, c.identifierConstant(stmt.name))
+		return nil
+	}
+	c.defineVariable(stmt.name)
+	return nil
+}
+
+func (c *Compiler) VisitBlockStmt(stmt *Block) any {
+	c.beginScope()
+	for _, s := range stmt.statements {
+		c.statement(s)
+	}
+	c.endScope()
+	return nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt *If) any {
+	c.expression(stmt.condition)
+	thenJump := c.emitJump(stmt.keyword, OP_JUMP_IF_FALSE)
+	c.emit(stmt.keyword, byte(OP_POP))
+	c.statement(stmt.trueBranch)
+	elseJump := c.emitJump(stmt.keyword, OP_JUMP)
+
+	c.patchJump(thenJump, stmt.keyword)
+	c.emit(stmt.keyword, byte(OP_POP))
+	if stmt.falseBranch != nil {
+		c.statement(stmt.falseBranch)
+	}
+	c.patchJump(elseJump, stmt.keyword)
+	return nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt *While) any {
+	start := len(c.chunk().code)
+	c.expression(stmt.condition)
+	exitJump := c.emitJump(stmt.keyword, OP_JUMP_IF_FALSE)
+	c.emit(stmt.keyword, byte(OP_POP))
+
+	l := &loop{label: stmt.label.Lexeme, scopeDepth: c.current.scopeDepth}
+	c.current.loops = append(c.current.loops, l)
+	c.statement(stmt.body)
+	c.current.loops = c.current.loops[:len(c.current.loops)-1]
+	// continues land on the increment so for loops still advance
+	for _, jump := range l.continues {
+		c.patchJump(jump, stmt.keyword)
+	}
+	if stmt.increment != nil {
+		c.expression(stmt.increment)
+		c.emit(stmt.keyword, byte(OP_POP))
+	}
+	c.emitLoop(start, stmt.keyword)
+
+	c.patchJump(exitJump, stmt.keyword)
+	c.emit(stmt.keyword, byte(OP_POP))
+	// breaks jump past the pop since they leave the loop after the condition was popped
+	for _, jump := range l.breaks {
+		c.patchJump(jump, stmt.keyword)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitThrowStmt(stmt *Throw) any {
+	c.expression(stmt.value)
+	c.emit(stmt.keyword, byte(OP_THROW))
+	return nil
+}
+
+func (c *Compiler) VisitTryStmt(stmt *Try) any {
+	t := &tryBlock{handler: true, finally: stmt.finallyBody, loops: len(c.current.loops)}
+	c.current.tries = append(c.current.tries, t)
+	handler := c.emitJump(stmt.keyword, OP_TRY)
+	c.statement(stmt.body)
+	c.emit(stmt.keyword, byte(OP_END_TRY))
+	// jumps to the finally clause run when nothing was raised
+	done := []int{c.emitJump(stmt.keyword, OP_JUMP)}
+	c.patchJump(handler, stmt.keyword)
+
+	if stmt.catchBody != nil {
+		// errors raised by the catch clause still have to run the finally clause
+		t.handler = stmt.finallyBody != nil
+		if t.handler {
+			handler = c.emitJump(stmt.keyword, OP_TRY)
+		}
+		c.emit(stmt.catchName, byte(OP_CAUGHT))
+		c.beginScope()
+		c.addLocal(stmt.catchName)
+		c.markInitialized()
+		c.statement(stmt.catchBody)
+		c.endScope()
+		if t.handler {
+			c.emit(stmt.keyword, byte(OP_END_TRY))
+			done = append(done, c.emitJump(stmt.keyword, OP_JUMP))
+			c.patchJump(handler, stmt.keyword)
+		}
+	}
+	c.current.tries = c.current.tries[:len(c.current.tries)-1]
+
+	if stmt.finallyBody != nil {
+		// the pending error sits below the locals of the finally clause until it's raised again
+		c.beginScope()
+		c.addLocal(scanner.Token{})
+		c.markInitialized()
+		c.statement(stmt.finallyBody)
+		c.dropHiddenLocal()
+		c.emit(stmt.keyword, byte(OP_RETHROW))
+	}
+	for _, jump := range done {
+		c.patchJump(jump, stmt.keyword)
+	}
+	if stmt.finallyBody != nil {
+		c.statement(stmt.finallyBody)
+	}
+	return nil
+}
+
+// exitTries leaves every try statement from the innermost one down to the one at index from,
+// dropping their handlers and running their finally clauses
+func (c *Compiler) exitTries(keyword scanner.Token, from int) {
+	tries := c.current.tries
+	for i := len(tries) - 1; i >= from; i-- {
+		if tries[i].handler {
+			c.emit(keyword, byte(OP_END_TRY))
+		}
+		if tries[i].finally != nil {
+			c.current.tries = tries[:i]
+			c.statement(tries[i].finally)
+		}
+	}
+	c.current.tries = tries
+}
+
+// dropHiddenLocal ends a scope holding only a local that isn't named in the source
+// without popping it, the instruction that follows consumes it
+func (c *Compiler) dropHiddenLocal() {
+	c.current.scopeDepth--
+	c.current.locals = c.current.locals[:len(c.current.locals)-1]
+}
+
+func (c *Compiler) VisitImportStmt(stmt *Import) any {
+	path := c.makeConstant(stmt.path, stmt.path.Literal)
+	if len(stmt.names) == 0 {
+		c.emitU16(stmt.path, OP_IMPORT, path)
+		c.defineVariable(stmt.alias)
+		return nil
+	}
+	// the module is cached after the first import so importing it again per name is cheap
+	for _, name := range stmt.names {
+		c.emitU16(stmt.path, OP_IMPORT, path)
+		c.emitU16(name, OP_GET_PROPERTY, c.identifierConstant(name))
+		c.defineVariable(name)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitBreakStatement(stmt *Break) any {
+	l := c.exitLoop(stmt.keyword, stmt.label)
+	l.breaks = append(l.breaks, c.emitJump(stmt.keyword, OP_JUMP))
+	return nil
+}
+
+func (c *Compiler) VisitContinueStmt(stmt *Continue) any {
+	l := c.exitLoop(stmt.keyword, stmt.label)
+	l.continues = append(l.continues, c.emitJump(stmt.keyword, OP_JUMP))
+	return nil
+}
+
+// exitLoop finds the loop a break or continue targets and pops the locals declared inside it
+func (c *Compiler) exitLoop(keyword, label scanner.Token) *loop {
+	index := len(c.current.loops) - 1
+	if label.Lexeme != "" {
+		for i := len(c.current.loops) - 1; i >= 0; i-- {
+			if c.current.loops[i].label == label.Lexeme {
+				index = i
+				break
+			}
+		}
+	}
+	l := c.current.loops[index]
+	// try statements inside the loop are left first, their finally clauses may use the locals
+	for i, t := range c.current.tries {
+		if t.loops > index {
+			c.exitTries(keyword, i)
+			break
+		}
+	}
+	// drop the locals without forgetting about them,
+	// the code after the jump is still compiled in the same scope
+	for i := len(c.current.locals) - 1; i >= 0 && c.current.locals[i].depth > l.scopeDepth; i-- {
+		if c.current.locals[i].captured {
+			c.emit(keyword, byte(OP_CLOSE_UPVALUE))
+		} else {
+			c.emit(keyword, byte(OP_POP))
+		}
+	}
+	return l
+}
+
+func (c *Compiler) VisitLambdaExpr(expr *Lambda) any {
+	c.function(expr.function, FUNCTION)
+	return nil
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt *Function) any {
+	// functions can refer to themselves so the name is usable before the body is compiled
+	if c.current.scopeDepth > 0 {
+		c.addLocal(stmt.name)
+		c.markInitialized()
+	}
+	c.function(stmt, FUNCTION)
+	if c.current.scopeDepth == 0 {
+		c.emitU16(stmt.name, OP_DEFINE_GLOBAL, c.identifierConstant(stmt.name))
+	}
+	return nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt *Return) any {
+	if c.current.prototype.functionType == CONSTRUCTOR {
+		c.emit(stmt.keyword, byte(OP_GET_LOCAL), 0)
+	} else if stmt.value != nil {
+		c.expression(stmt.value)
+	} else {
+		c.emit(stmt.keyword, byte(OP_NIL))
+	}
+	if len(c.current.tries) > 0 {
+		// keep the returned value in a hidden local while the finally clauses run
+		c.beginScope()
+		c.addLocal(scanner.Token{})
+		c.markInitialized()
+		c.exitTries(stmt.keyword, 0)
+		c.dropHiddenLocal()
+	}
+	c.emit(stmt.keyword, byte(OP_RETURN))
+	return nil
+}
+
+func (c *Compiler) VisitClassStmt(stmt *Class) any {
+	nameConstant := c.identifierConstant(stmt.name)
+	c.emitU16(stmt.name, OP_CLASS, nameConstant)
+	c.defineVariable(stmt.name)
+
+	if stmt.superclass != nil {
+		// the superclass is kept in a local named "super" that methods capture
+		c.beginScope()
+		c.expression(stmt.superclass)
+		c.addLocal(scanner.Token{Lexeme: "super"})
+		c.markInitialized()
+		c.namedVariable(stmt.name, false)
+		c.emit(stmt.superclass.name, byte(OP_INHERIT))
+	}
+
+	c.namedVariable(stmt.name, false)
+	for _, method := range stmt.methods {
+		methodConstant := c.identifierConstant(method.name)
+		switch {
+		case method.functionType == STATIC_METHOD:
+			c.function(method, STATIC_METHOD)
+			c.emitU16(method.name, OP_STATIC_METHOD, methodConstant)
+		case method.name.Lexeme == "init":
+			c.function(method, CONSTRUCTOR)
+			c.emitU16(method.name, OP_METHOD, methodConstant)
+		default:
+			c.function(method, METHOD)
+			c.emitU16(method.name, OP_METHOD, methodConstant)
+		}
+	}
+	c.emit(stmt.name, byte(OP_POP))
+
+	if stmt.superclass != nil {
+		c.endScope()
+	}
+
+	for _, field := range stmt.staticFields {
+		c.namedVariable(stmt.name, false)
+		c.expression(field.initializer)
+		c.emitU16(field.name, OP_SET_PROPERTY, c.identifierConstant(field.name))
+		c.emit(field.name, byte(OP_POP))
+	}
+	return nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *Literal) any {
+	switch expr.value {
+	case nil:
+		c.emit(expr.token, byte(OP_NIL))
+	case true:
+		c.emit(expr.token, byte(OP_TRUE))
+	case false:
+		c.emit(expr.token, byte(OP_FALSE))
+	default:
+		c.emitConstant(expr.token, expr.value)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *Grouping) any {
+	c.expression(expr.expression)
+	return nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *Unary) any {
+	c.expression(expr.right)
+	switch expr.operator.Type {
+	case scanner.MINUS:
+		c.emit(expr.operator, byte(OP_NEGATE))
+	case scanner.BANG:
+		c.emit(expr.operator, byte(OP_NOT))
+	}
+	return nil
+}
+
+func (c *Compiler) VisitCompoundAssignExpr(expr *CompoundAssign) any {
+	c.update(expr.target, expr.operator, false, func() {
+		c.expression(expr.value)
+		c.binary(expr.binaryOperator())
+	})
+	return nil
+}
+
+func (c *Compiler) VisitUpdateExpr(expr *Update) any {
+	c.update(expr.target, expr.operator, !expr.prefix, func() {
+		if expr.operator.Type == scanner.INCREMENT {
+			c.emit(expr.operator, byte(OP_INCREMENT))
+		} else {
+			c.emit(expr.operator, byte(OP_DECREMENT))
+		}
+	})
+	return nil
+}
+
+// update compiles replacing the value of target, a variable, field or indexed element,
+// with the result of the code modify emits for it. the object and index of the target
+// stay on the stack to be used for both reading and writing, so they are evaluated once.
+// the new value is left on the stack, or the old one when keepOld is set.
+func (c *Compiler) update(target Expr, operator scanner.Token, keepOld bool, modify func()) {
+	var operands int
+	var get, set func()
+	switch target := target.(type) {
+	case *Variable:
+		get = func() { c.namedVariable(target.name, false) }
+		set = func() { c.namedVariable(target.name, true) }
+	case *Get:
+		c.expression(target.instance)
+		operands = 1
+		name := c.identifierConstant(target.name)
+		get = func() { c.emitU16(target.name, OP_GET_PROPERTY, name) }
+		set = func() { c.emitU16(target.name, OP_SET_PROPERTY, name) }
+	case *Index:
+		c.expression(target.object)
+		c.expression(target.index)
+		operands = 2
+		get = func() { c.emit(target.bracket, byte(OP_GET_INDEX)) }
+		set = func() { c.emit(target.bracket, byte(OP_SET_INDEX)) }
+	default:
+		c.emitU16(operator, OP_ERROR, c.makeConstant(operator, invalidTarget(operator)))
+		return
+	}
+
+	for i := 0; i < operands; i++ {
+		c.emit(operator, byte(OP_PICK), byte(operands-1))
+	}
+	get()
+	if keepOld {
+		// [operands old] becomes [old operands old]
+		if operands > 0 {
+			c.emit(operator, byte(OP_BURY), byte(operands))
+		}
+		c.emit(operator, byte(OP_PICK), byte(operands))
+	}
+	modify()
+	set()
+	if keepOld {
+		c.emit(operator, byte(OP_POP))
+	}
+}
+
+func (c *Compiler) VisitBinaryExpr(expr *Binary) any {
+	c.expression(expr.left)
+	if expr.operator.Type == scanner.COMMA {
+		c.emit(expr.operator, byte(OP_POP))
+		c.expression(expr.right)
+		return nil
+	}
+	c.expression(expr.right)
+	c.binary(expr.operator)
+	return nil
+}
+
+// binary emits the instruction applying a comparison or arithmetic operator to the two values on top of the stack
+func (c *Compiler) binary(operator scanner.Token) {
+	switch operator.Type {
+	case scanner.EQUAL_EQUAL:
+		c.emit(operator, byte(OP_EQUAL))
+	case scanner.BANG_EQUAL:
+		c.emit(operator, byte(OP_EQUAL), byte(OP_NOT))
+	case scanner.GREATER:
+		c.emit(operator, byte(OP_GREATER))
+	case scanner.GREATER_EQUAL:
+		c.emit(operator, byte(OP_GREATER_EQUAL))
+	case scanner.LESS:
+		c.emit(operator, byte(OP_LESS))
+	case scanner.LESS_EQUAL:
+		c.emit(operator, byte(OP_LESS_EQUAL))
+	case scanner.PLUS:
+		c.emit(operator, byte(OP_ADD))
+	case scanner.MINUS:
+		c.emit(operator, byte(OP_SUBTRACT))
+	case scanner.STAR:
+		c.emit(operator, byte(OP_MULTIPLY))
+	case scanner.SLASH:
+		c.emit(operator, byte(OP_DIVIDE))
+	case scanner.MODULO:
+		c.emit(operator, byte(OP_MODULO))
+	}
+}
+
+func (c *Compiler) VisitTernaryExpr(expr *Ternary) any {
+	c.expression(expr.condition)
+	token := exprToken(expr.condition)
+	c.emit(token, byte(OP_CHECK_BOOL))
+	elseJump := c.emitJump(token, OP_JUMP_IF_FALSE)
+	c.emit(token, byte(OP_POP))
+	c.expression(expr.trueBranch)
+	endJump := c.emitJump(token, OP_JUMP)
+	c.patchJump(elseJump, token)
+	c.emit(token, byte(OP_POP))
+	c.expression(expr.falseBranch)
+	c.patchJump(endJump, token)
+	return nil
+}
+
+func (c *Compiler) VisitLogicalExpr(expr *Logical) any {
+	c.expression(expr.left)
+	if expr.operator.Type == scanner.OR {
+		// jump over the right operand when the left one is truthy
+		elseJump := c.emitJump(expr.operator, OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(expr.operator, OP_JUMP)
+		c.patchJump(elseJump, expr.operator)
+		c.emit(expr.operator, byte(OP_POP))
+		c.expression(expr.right)
+		c.patchJump(endJump, expr.operator)
+	} else {
+		endJump := c.emitJump(expr.operator, OP_JUMP_IF_FALSE)
+		c.emit(expr.operator, byte(OP_POP))
+		c.expression(expr.right)
+		c.patchJump(endJump, expr.operator)
+	}
+	return nil
+}
+
+func (c *Compiler) VisitVariableExpr(expr *Variable) any {
+	c.namedVariable(expr.name, false)
+	return nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr *Assign) any {
+	c.expression(expr.value)
+	c.namedVariable(expr.name, true)
+	return nil
+}
+
+func (c *Compiler) VisitCallExpr(expr *Call) any {
+	c.expression(expr.callee)
+	var named []scanner.Token
+	spread := false
+	for _, argument := range expr.arguments {
+		c.expression(argument.value)
+		if argument.isSpread() {
+			c.emit(argument.spread, byte(OP_SPREAD))
+			spread = true
+		}
+		if argument.isNamed() {
+			named = append(named, argument.name)
+		}
+	}
+	if len(expr.arguments) > math.MaxUint8 {
+		c.error(expr.paren, diagnostics.TooManyArguments, "can't have more than 255 arguments")
+	}
+	if !spread && len(named) == 0 {
+		c.emit(expr.paren, byte(OP_CALL), byte(len(expr.arguments)))
+		return nil
+	}
+	// named arguments always come last, their names follow in the order they're on the stack
+	c.emit(expr.paren, byte(OP_CALL_ARGS), byte(len(expr.arguments)-len(named)), byte(len(named)))
+	for _, name := range named {
+		index := c.identifierConstant(name)
+		c.emit(name, byte(index>>8), byte(index))
+	}
+	return nil
+}
+
+func (c *Compiler) VisitGetExpr(expr *Get) any {
+	c.expression(expr.instance)
+	c.emitU16(expr.name, OP_GET_PROPERTY, c.identifierConstant(expr.name))
+	return nil
+}
+
+func (c *Compiler) VisitSetExpr(expr *Set) any {
+	c.expression(expr.object)
+	c.expression(expr.value)
+	c.emitU16(expr.name, OP_SET_PROPERTY, c.identifierConstant(expr.name))
+	return nil
+}
+
+func (c *Compiler) VisitThisExpr(expr *This) any {
+	c.namedVariable(expr.keyword, false)
+	return nil
+}
+
+func (c *Compiler) VisitSuperExpr(expr *Super) any {
+	c.namedVariable(scanner.Token{Type: scanner.THIS, Lexeme: "this", Line: expr.keyword.Line, Column: expr.keyword.Column, Start: expr.keyword.Start, End: expr.keyword.End}, false)
+	c.namedVariable(expr.keyword, false)
+	c.emitU16(expr.method, OP_GET_SUPER, c.identifierConstant(expr.method))
+	return nil
+}
+
+func (c *Compiler) VisitInterpolationExpr(expr *Interpolation) any {
+	for _, part := range expr.parts {
+		c.expression(part)
+	}
+	c.emitU16(expr.start, OP_INTERPOLATE, len(expr.parts))
+	return nil
+}
+
+func (c *Compiler) VisitListExpr(expr *List) any {
+	for _, element := range expr.elements {
+		c.expression(element)
+	}
+	c.emitU16(expr.bracket, OP_LIST, len(expr.elements))
+	return nil
+}
+
+func (c *Compiler) VisitMapExpr(expr *Map) any {
+	for i := range expr.keys {
+		c.expression(expr.keys[i])
+		c.expression(expr.values[i])
+	}
+	c.emitU16(expr.brace, OP_MAP, len(expr.keys))
+	return nil
+}
+
+func (c *Compiler) VisitIndexExpr(expr *Index) any {
+	c.expression(expr.object)
+	c.expression(expr.index)
+	c.emit(expr.bracket, byte(OP_GET_INDEX))
+	return nil
+}
+
+func (c *Compiler) VisitIndexSetExpr(expr *IndexSet) any {
+	c.expression(expr.object)
+	c.expression(expr.index)
+	c.expression(expr.value)
+	c.emit(expr.bracket, byte(OP_SET_INDEX))
+	return nil
+}
+
+// function compiles declaration into its own prototype and emits the closure creating it
+func (c *Compiler) function(declaration *Function, functionType FunctionType) {
+	prototype := &Prototype{name: declaration.name.Lexeme, class: declaration.class, params: declaration.parameters(), functionType: functionType}
+	prototype.minArity, prototype.maxArity = declaration.arity()
+	receiver := ""
+	if functionType != FUNCTION {
+		receiver = "this"
+	}
+	c.beginFunction(prototype, receiver)
+	c.beginScope()
+	for i, param := range declaration.params {
+		c.addLocal(param)
+		if declaration.defaults[i] != nil {
+			c.defaultValue(param, declaration.defaults[i])
+		}
+		c.markInitialized()
+	}
+	for _, stmt := range declaration.body {
+		c.statement(stmt)
+	}
+	upvalues := c.current.upvalues
+	c.endFunction()
+
+	c.emitU16(declaration.name, OP_CLOSURE, c.makeConstant(declaration.name, prototype))
+	for _, upvalue := range upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.emit(declaration.name, isLocal, upvalue.index)
+	}
+}
+
+// defaultValue sets the parameter just added as a local to value when the caller left it out.
+// the parameter is still uninitialized so its own default can't refer to it.
+func (c *Compiler) defaultValue(param scanner.Token, value Expr) {
+	slot := len(c.current.locals) - 1
+	c.emit(param, byte(OP_DEFAULT), byte(slot))
+	skip := c.emitJumpOperand(param)
+	c.expression(value)
+	c.emit(param, byte(OP_SET_LOCAL), byte(slot), byte(OP_POP))
+	c.patchJump(skip, param)
+}
+
+// beginFunction starts compiling a new function, slot 0 holds the receiver for methods and the callee otherwise
+func (c *Compiler) beginFunction(prototype *Prototype, receiver string) {
+	c.current = &functionCompiler{
+		enclosing: c.current,
+		prototype: prototype,
+		locals:    []local{{name: receiver}},
+	}
+}
+
+func (c *Compiler) endFunction() *Prototype {
+	if c.current.prototype.functionType == CONSTRUCTOR {
+		c.emit(scanner.Token{}, byte(OP_GET_LOCAL), 0)
+	} else {
+		c.emit(scanner.Token{}, byte(OP_NIL))
+	}
+	c.emit(scanner.Token{}, byte(OP_RETURN))
+
+	prototype := c.current.prototype
+	prototype.upvalueCount = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return prototype
+}
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.current.scopeDepth--
+	locals := c.current.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.current.scopeDepth {
+		if locals[len(locals)-1].captured {
+			c.emit(scanner.Token{}, byte(OP_CLOSE_UPVALUE))
+		} else {
+			c.emit(scanner.Token{}, byte(OP_POP))
+		}
+		locals = locals[:len(locals)-1]
+	}
+	c.current.locals = locals
+}
+
+// defineVariable binds the value on top of the stack to name, locals simply stay where they are
+func (c *Compiler) defineVariable(name scanner.Token) {
+	if c.current.scopeDepth > 0 {
+		c.addLocal(name)
+		c.markInitialized()
+		return
+	}
+	c.emitU16(name, OP_DEFINE_GLOBAL, c.identifierConstant(name))
+}
+
+func (c *Compiler) addLocal(name scanner.Token) {
+	if len(c.current.locals) > math.MaxUint8 {
+		c.error(name, diagnostics.TooManyLocals, "too many local variables in function")
+		return
+	}
+	c.current.locals = append(c.current.locals, local{name: name.Lexeme, depth: -1})
+}
+
+func (c *Compiler) markInitialized() {
+	c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+}
+
+// namedVariable emits a read, or a write of the value on top of the stack, of the variable called name
+func (c *Compiler) namedVariable(name scanner.Token, assign bool) {
+	if slot := c.current.resolveLocal(name.Lexeme); slot >= 0 {
+		if assign {
+			c.emit(name, byte(OP_SET_LOCAL), byte(slot))
+		} else {
+			c.emit(name, byte(OP_GET_LOCAL), byte(slot))
+		}
+	} else if index := c.resolveUpvalue(c.current, name); index >= 0 {
+		if assign {
+			c.emit(name, byte(OP_SET_UPVALUE), byte(index))
+		} else {
+			c.emit(name, byte(OP_GET_UPVALUE), byte(index))
+		}
+	} else if assign {
+		c.emitU16(name, OP_SET_GLOBAL, c.identifierConstant(name))
+	} else {
+		c.emitU16(name, OP_GET_GLOBAL, c.identifierConstant(name))
+	}
+}
+
+func (f *functionCompiler) resolveLocal(name string) int {
+	for i := len(f.locals) - 1; i >= 0; i-- {
+		if f.locals[i].name == name && f.locals[i].depth != -1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveUpvalue finds name in the enclosing functions and threads it down to f as an upvalue
+func (c *Compiler) resolveUpvalue(f *functionCompiler, name scanner.Token) int {
+	if f.enclosing == nil {
+		return -1
+	}
+	if slot := f.enclosing.resolveLocal(name.Lexeme); slot >= 0 {
+		f.enclosing.locals[slot].captured = true
+		return c.addUpvalue(f, name, byte(slot), true)
+	}
+	if index := c.resolveUpvalue(f.enclosing, name); index >= 0 {
+		return c.addUpvalue(f, name, byte(index), false)
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(f *functionCompiler, name scanner.Token, index byte, isLocal bool) int {
+	for i, upvalue := range f.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+	if len(f.upvalues) > math.MaxUint8 {
+		c.error(name, diagnostics.TooManyUpvalues, "too many closure variables in function")
+		return 0
+	}
+	f.upvalues = append(f.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	return len(f.upvalues) - 1
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.prototype.chunk
+}
+
+func (c *Compiler) emit(token scanner.Token, bytes ...byte) {
+	for _, b := range bytes {
+		c.chunk().write(b, token)
+	}
+}
+
+func (c *Compiler) emitU16(token scanner.Token, op OpCode, operand int) {
+	c.emit(token, byte(op), byte(operand>>8), byte(operand))
+}
+
+func (c *Compiler) emitConstant(token scanner.Token, value any) {
+	c.emitU16(token, OP_CONSTANT, c.makeConstant(token, value))
+}
+
+func (c *Compiler) makeConstant(token scanner.Token, value any) int {
+	index := c.chunk().addConstant(value)
+	if index > math.MaxUint16 {
+		c.error(token, diagnostics.TooManyConstants, "too many constants in one chunk")
+		return 0
+	}
+	return index
+}
+
+func (c *Compiler) identifierConstant(name scanner.Token) int {
+	return c.makeConstant(name, name.Lexeme)
+}
+
+// emitJump emits a jump with a placeholder offset and returns where the offset is so patchJump can fill it in
+func (c *Compiler) emitJump(token scanner.Token, op OpCode) int {
+	c.emit(token, byte(op))
+	return c.emitJumpOperand(token)
+}
+
+// emitJumpOperand emits a jump offset to patch later for opcodes with other operands before it
+func (c *Compiler) emitJumpOperand(token scanner.Token) int {
+	c.emit(token, 0xff, 0xff)
+	return len(c.chunk().code) - 2
+}
+
+func (c *Compiler) patchJump(offset int, token scanner.Token) {
+	jump := len(c.chunk().code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.error(token, diagnostics.JumpTooLarge, "too much code to jump over")
+	}
+	c.chunk().code[offset] = byte(jump >> 8)
+	c.chunk().code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(start int, token scanner.Token) {
+	offset := len(c.chunk().code) - start + 3
+	if offset > math.MaxUint16 {
+		c.error(token, diagnostics.JumpTooLarge, "loop body too large")
+	}
+	c.emitU16(token, OP_LOOP, offset)
+}
+
+func (c *Compiler) error(token scanner.Token, code diagnostics.Code, message string) {
+	c.reporter.Report(diagnostics.Error(code, token.Span(), message))
+}
+
+// exprToken finds a token to blame for errors about expr as a whole
+func exprToken(expr Expr) scanner.Token {
+	span := exprSpan(expr)
+	return scanner.Token{Line: span.Line, Column: span.Column, Start: span.Start, End: span.End}
+}
@@ -0,0 +1,28 @@
+package ast
+
+type CompletionType int
+
+const (
+	NORMAL CompletionType = iota
+	BREAK
+	CONTINUE
+	RETURN
+)
+
+// completion records how executing a statement ended. statements hand it back up
+// until the loop or function it targets, every other statement just passes it along.
+// it's returned by Interpreter.execute rather than through accept so it's never boxed.
+type completion struct {
+	kind CompletionType
+	// value is what a return returns
+	value any
+	// label names the loop a break or continue targets, it's empty for the innermost one
+	label string
+}
+
+var normal = completion{}
+
+// targets reports whether a break or continue is aimed at loop
+func (c completion) targets(loop *While) bool {
+	return c.label == "" || c.label == loop.label.Lexeme
+}
@@ -4,22 +4,40 @@ import (
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
+// Environment holds globals and natives by name since scripts, the REPL and go code can add new ones at any time.
+// locals live in frames instead.
 type Environment struct {
-	values    map[string]any
+	values map[string]any
+	// constants can't be assigned to nor declared again
+	constants map[string]bool
 	enclosing *Environment
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
-	return &Environment{values: make(map[string]any), enclosing: enclosing}
+	return &Environment{values: make(map[string]any), constants: make(map[string]bool), enclosing: enclosing}
 }
 
 func (env *Environment) define(name string, value any) {
 	env.values[name] = value
 }
 
+// declare defines a global declared by a script, a constant can't be declared again
+func (env *Environment) declare(name scanner.Token, value any, constant bool) {
+	if env.constants[name.Lexeme] {
+		panic(&RuntimeError{Message: "can't redeclare constant '" + name.Lexeme + "'", Token: name})
+	}
+	env.values[name.Lexeme] = value
+	if constant {
+		env.constants[name.Lexeme] = true
+	}
+}
+
 func (env *Environment) assign(name scanner.Token, value any) {
 	_, ok := env.values[name.Lexeme]
 	if ok {
+		if env.constants[name.Lexeme] {
+			panic(&RuntimeError{Message: "can't assign to constant '" + name.Lexeme + "'", Token: name})
+		}
 		env.values[name.Lexeme] = value
 		return
 	}
@@ -30,11 +48,6 @@ func (env *Environment) assign(name scanner.Token, value any) {
 	panic(&RuntimeError{Message: "assign: undefined variable '" + name.Lexeme + "'", Token: name})
 }
 
-func (env *Environment) assignAt(depth int, name scanner.Token, value any) {
-	e := env.ancestor(depth)
-	e.values[name.Lexeme] = value
-}
-
 func (env *Environment) get(name scanner.Token) any {
 	if value, ok := env.values[name.Lexeme]; ok {
 		return value
@@ -45,14 +58,27 @@ func (env *Environment) get(name scanner.Token) any {
 	panic(&RuntimeError{Message: "undefined variable '" + name.Lexeme + "'", Token: name})
 }
 
-func (env *Environment) getAt(depth int, name string) any {
-	return env.ancestor(depth).values[name]
+// binding is where the resolver found a variable: depth scopes up from where it's used, at index slot.
+// unresolved bindings are globals.
+type binding struct {
+	resolved bool
+	depth    int
+	slot     int
+}
+
+// frame holds the locals of a single scope in the slots the resolver gave them
+type frame struct {
+	slots     []any
+	enclosing *frame
+}
+
+func newFrame(size int, enclosing *frame) *frame {
+	return &frame{slots: make([]any, size), enclosing: enclosing}
 }
 
-func (env *Environment) ancestor(depth int) *Environment {
-	e := env
+func (f *frame) ancestor(depth int) *frame {
 	for i := 0; i < depth; i++ {
-		e = e.enclosing
+		f = f.enclosing
 	}
-	return e
+	return f
 }
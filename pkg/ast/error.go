@@ -1,6 +1,11 @@
 package ast
 
-import "github.com/fadyZohdy/gLox/pkg/scanner"
+import (
+	"fmt"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
 
 type ParseError struct{}
 
@@ -13,12 +18,44 @@ var ParseErrorObj = &ParseError{}
 type RuntimeError struct {
 	Message string
 	Token   scanner.Token
+	// Value is the lox value a throw statement raised, it's nil for errors raised by the runtime itself
+	Value any
+	// Stack lists the lox calls active when the error was raised, innermost first
+	Stack []StackFrame
+	// File and Source are the imported module the error was raised in, Token points into Source.
+	// they're empty when it was raised in the entry script.
+	File   string
+	Source string
+}
+
+// StackFrame is a call in the stack trace of a runtime error. Line is where the function was
+// executing when the error was raised, or where it called the next function of the trace.
+// the top level script is called "script".
+type StackFrame struct {
+	Function string
+	// Class is the class declaring the function when it's a method
+	Class string
+	// File is the script the function is in, it's empty when the script wasn't named
+	File string
+	Line int
+}
+
+func (frame StackFrame) String() string {
+	name := frame.Function
+	if frame.Class != "" {
+		name = frame.Class + "." + name
+	}
+	if frame.File == "" {
+		return fmt.Sprintf("at %s (line %d)", name, frame.Line)
+	}
+	return fmt.Sprintf("at %s (%s:%d)", name, frame.File, frame.Line)
 }
 
 func (err RuntimeError) Error() string {
 	return err.Message
 }
 
+// the errors below only give the message of common runtime errors, panicWithToken raises a fresh copy of them
 var OnlyStringOrNumberError = &RuntimeError{Message: "operands can be numbers or strings"}
 
 var DivisionByZeroError = &RuntimeError{Message: "division by zero"}
@@ -28,3 +65,31 @@ var UnknownOperatorError = &RuntimeError{Message: "unknown operator"}
 var NotNumberError = &RuntimeError{Message: "operand is not a number"}
 
 var NotStringError = &RuntimeError{Message: "operand is not a string"}
+
+// Diagnostic describes the error the same way static errors are described so it can be rendered with them
+func (err *RuntimeError) Diagnostic() *diagnostics.Diagnostic {
+	d := diagnostics.Error("", err.Token.Span(), err.Message)
+	for _, frame := range err.Stack {
+		d.WithNote(frame.String())
+	}
+	return d
+}
+
+// caught is the value a catch clause binds for err
+func (err *RuntimeError) caught() any {
+	if err.Value != nil {
+		return err.Value
+	}
+	return &LoxError{message: err.Message, line: err.Token.Line}
+}
+
+// throw raises value as a lox exception from keyword
+func throw(value any, keyword scanner.Token) {
+	if value == nil {
+		panic(&RuntimeError{Message: "can't throw nil", Token: keyword})
+	}
+	if e, ok := value.(*LoxError); ok && e.line == 0 {
+		e.line = keyword.Line
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("uncaught exception: %v", value), Token: keyword, Value: value})
+}
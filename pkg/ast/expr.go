@@ -1,9 +1,14 @@
 package ast
 
-import "github.com/fadyZohdy/gLox/pkg/scanner"
+import (
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
 
 type Expr interface {
 	accept(visitor Visitor) any
+	// Span locates the expression in the source, it is zero for synthesized nodes
+	Span() diagnostics.Span
 }
 
 type Binary struct {
@@ -16,22 +21,37 @@ func (expr *Binary) accept(visitor Visitor) any {
 	return visitor.VisitBinaryExpr(expr)
 }
 
+func (expr *Binary) Span() diagnostics.Span {
+	return exprSpan(expr.left).To(expr.operator.Span()).To(exprSpan(expr.right))
+}
+
 type Grouping struct {
 	expression Expr
+	paren      scanner.Token
+	end        scanner.Token
 }
 
 func (expr *Grouping) accept(visitor Visitor) any {
 	return visitor.VisitGroupingExpr(expr)
 }
 
+func (expr *Grouping) Span() diagnostics.Span {
+	return expr.paren.Span().To(expr.end.Span())
+}
+
 type Literal struct {
 	value interface{}
+	token scanner.Token
 }
 
 func (expr *Literal) accept(visitor Visitor) any {
 	return visitor.VisitLiteralExpr(expr)
 }
 
+func (expr *Literal) Span() diagnostics.Span {
+	return expr.token.Span()
+}
+
 type Unary struct {
 	operator scanner.Token
 	right    Expr
@@ -41,6 +61,10 @@ func (expr *Unary) accept(visitor Visitor) any {
 	return visitor.VisitUnaryExpr(expr)
 }
 
+func (expr *Unary) Span() diagnostics.Span {
+	return expr.operator.Span().To(exprSpan(expr.right))
+}
+
 type Ternary struct {
 	condition   Expr
 	trueBranch  Expr
@@ -51,6 +75,10 @@ func (expr *Ternary) accept(visitor Visitor) any {
 	return visitor.VisitTernaryExpr(expr)
 }
 
+func (expr *Ternary) Span() diagnostics.Span {
+	return exprSpan(expr.condition).To(exprSpan(expr.falseBranch))
+}
+
 type Logical struct {
 	operator    scanner.Token
 	left, right Expr
@@ -60,33 +88,66 @@ func (expr *Logical) accept(v Visitor) any {
 	return v.VisitLogicalExpr(expr)
 }
 
+func (expr *Logical) Span() diagnostics.Span {
+	return exprSpan(expr.left).To(exprSpan(expr.right))
+}
+
 type Variable struct {
 	name scanner.Token
+	binding
 }
 
 func (expr *Variable) accept(visitor Visitor) any {
 	return visitor.VisitVariableExpr(expr)
 }
 
+func (expr *Variable) Span() diagnostics.Span {
+	return expr.name.Span()
+}
+
 type Assign struct {
 	name  scanner.Token
 	value Expr
+	binding
 }
 
 func (expr *Assign) accept(visitor Visitor) any {
 	return visitor.VisitAssignExpr(expr)
 }
 
+func (expr *Assign) Span() diagnostics.Span {
+	return expr.name.Span().To(exprSpan(expr.value))
+}
+
 type Call struct {
 	callee    Expr
 	paren     scanner.Token
-	arguments []Expr
+	arguments []argument
+}
+
+// argument is a single argument of a call, name is set for `name: value` and spread for `...list`
+type argument struct {
+	name   scanner.Token
+	spread scanner.Token
+	value  Expr
+}
+
+func (a argument) isNamed() bool {
+	return a.name.Lexeme != ""
+}
+
+func (a argument) isSpread() bool {
+	return a.spread.Lexeme != ""
 }
 
 func (expr *Call) accept(visitor Visitor) any {
 	return visitor.VisitCallExpr(expr)
 }
 
+func (expr *Call) Span() diagnostics.Span {
+	return exprSpan(expr.callee).To(expr.paren.Span())
+}
+
 type Get struct {
 	instance Expr
 	name     scanner.Token
@@ -96,6 +157,10 @@ func (expr *Get) accept(visitor Visitor) any {
 	return visitor.VisitGetExpr(expr)
 }
 
+func (expr *Get) Span() diagnostics.Span {
+	return exprSpan(expr.instance).To(expr.name.Span())
+}
+
 type Set struct {
 	object Expr
 	name   scanner.Token
@@ -106,10 +171,174 @@ func (expr *Set) accept(visitor Visitor) any {
 	return visitor.VisitSetExpr(expr)
 }
 
+func (expr *Set) Span() diagnostics.Span {
+	return exprSpan(expr.object).To(exprSpan(expr.value))
+}
+
 type This struct {
 	keyword scanner.Token
+	binding
 }
 
 func (expr *This) accept(visitor Visitor) any {
 	return visitor.VisitThisExpr(expr)
 }
+
+func (expr *This) Span() diagnostics.Span {
+	return expr.keyword.Span()
+}
+
+type Super struct {
+	keyword scanner.Token
+	method  scanner.Token
+	binding
+}
+
+func (expr *Super) accept(visitor Visitor) any {
+	return visitor.VisitSuperExpr(expr)
+}
+
+func (expr *Super) Span() diagnostics.Span {
+	return expr.keyword.Span().To(expr.method.Span())
+}
+
+// CompoundAssign is an assignment like `x += 1` to a variable, field or indexed element,
+// the object and index of the target are evaluated once
+type CompoundAssign struct {
+	target   Expr
+	operator scanner.Token
+	value    Expr
+}
+
+// compoundOperators maps each compound assignment operator to the binary operator it applies
+var compoundOperators = map[scanner.TokenType]scanner.TokenType{
+	scanner.PLUS_EQUAL:   scanner.PLUS,
+	scanner.MINUS_EQUAL:  scanner.MINUS,
+	scanner.STAR_EQUAL:   scanner.STAR,
+	scanner.SLASH_EQUAL:  scanner.SLASH,
+	scanner.MODULO_EQUAL: scanner.MODULO,
+}
+
+// binaryOperator is the operator token with the type of the binary operator it applies
+func (expr *CompoundAssign) binaryOperator() scanner.Token {
+	operator := expr.operator
+	operator.Type = compoundOperators[operator.Type]
+	return operator
+}
+
+func (expr *CompoundAssign) accept(visitor Visitor) any {
+	return visitor.VisitCompoundAssignExpr(expr)
+}
+
+func (expr *CompoundAssign) Span() diagnostics.Span {
+	return exprSpan(expr.target).To(exprSpan(expr.value))
+}
+
+// Update is a prefix or postfix increment or decrement. it evaluates to the new value when prefix
+// and to the old one otherwise. the target is only checked to be assignable at runtime.
+type Update struct {
+	operator scanner.Token
+	target   Expr
+	prefix   bool
+}
+
+func (expr *Update) accept(visitor Visitor) any {
+	return visitor.VisitUpdateExpr(expr)
+}
+
+func (expr *Update) Span() diagnostics.Span {
+	if expr.prefix {
+		return expr.operator.Span().To(exprSpan(expr.target))
+	}
+	return exprSpan(expr.target).To(expr.operator.Span())
+}
+
+// Lambda is an anonymous function, either `fun (a) { ... }` or `(a) => ...`.
+// the body of the arrow form is desugared into a single return statement.
+type Lambda struct {
+	keyword  scanner.Token
+	function *Function
+}
+
+func (expr *Lambda) accept(visitor Visitor) any {
+	return visitor.VisitLambdaExpr(expr)
+}
+
+func (expr *Lambda) Span() diagnostics.Span {
+	return expr.keyword.Span().To(expr.function.Span())
+}
+
+// Interpolation is a string literal with embedded expressions, e.g. "hi ${name}".
+// parts alternate between the string Literals and the expressions, starting and ending with a Literal.
+type Interpolation struct {
+	start scanner.Token
+	parts []Expr
+	end   scanner.Token
+}
+
+func (expr *Interpolation) accept(visitor Visitor) any {
+	return visitor.VisitInterpolationExpr(expr)
+}
+
+func (expr *Interpolation) Span() diagnostics.Span {
+	return expr.start.Span().To(expr.end.Span())
+}
+
+type List struct {
+	bracket  scanner.Token
+	elements []Expr
+	end      scanner.Token
+}
+
+func (expr *List) accept(visitor Visitor) any {
+	return visitor.VisitListExpr(expr)
+}
+
+func (expr *List) Span() diagnostics.Span {
+	return expr.bracket.Span().To(expr.end.Span())
+}
+
+type Index struct {
+	object  Expr
+	bracket scanner.Token
+	index   Expr
+	end     scanner.Token
+}
+
+func (expr *Index) accept(visitor Visitor) any {
+	return visitor.VisitIndexExpr(expr)
+}
+
+func (expr *Index) Span() diagnostics.Span {
+	return exprSpan(expr.object).To(expr.end.Span())
+}
+
+type IndexSet struct {
+	object  Expr
+	bracket scanner.Token
+	index   Expr
+	value   Expr
+}
+
+func (expr *IndexSet) accept(visitor Visitor) any {
+	return visitor.VisitIndexSetExpr(expr)
+}
+
+func (expr *IndexSet) Span() diagnostics.Span {
+	return exprSpan(expr.object).To(exprSpan(expr.value))
+}
+
+type Map struct {
+	brace  scanner.Token
+	keys   []Expr
+	values []Expr
+	end    scanner.Token
+}
+
+func (expr *Map) accept(visitor Visitor) any {
+	return visitor.VisitMapExpr(expr)
+}
+
+func (expr *Map) Span() diagnostics.Span {
+	return expr.brace.Span().To(expr.end.Span())
+}
@@ -7,4 +7,6 @@ const (
 	METHOD
 	STATIC_METHOD
 	CONSTRUCTOR
+	// SCRIPT is the top level code the bytecode compiler wraps in a function
+	SCRIPT
 )
@@ -1,34 +1,114 @@
 package ast
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"strings"
 
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
 type Interpreter struct {
-	errorReporter    func(error *RuntimeError)
-	env              *Environment
-	breakEncountered bool
-	returnValue      any
-	locals           map[Expr]int
+	errorReporter func(error *RuntimeError)
+	// builtins holds the natives and encloses the globals of every module,
+	// so scripts can shadow a native without losing it for everyone else
+	builtins *Environment
+	// main is the module of the script being run, module the one whose code is executing.
+	// functions run in the module declaring them.
+	main    *LoxModule
+	module  *LoxModule
+	modules modules
+	// frame holds the locals of the innermost scope being executed, it's nil at the top level
+	frame *frame
+	// calls are the lox calls being executed, innermost last, used for stack traces
+	calls []call
+	// everything a script prints goes to stdout, stdin is what input natives read from
+	stdout io.Writer
+	stdin  *bufio.Reader
+}
+
+// Option customizes an Interpreter created by NewInterpreter
+type Option func(i *Interpreter)
+
+// WithStdout redirects the output of print statements and the io natives to w
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdout = w
+	}
+}
+
+// WithStdin makes scripts read their input from r instead of os.Stdin
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) {
+		i.stdin = bufio.NewReader(r)
+	}
+}
+
+// WithFileName names the script being run in the stack traces of runtime errors
+func WithFileName(name string) Option {
+	return func(i *Interpreter) {
+		i.main.path = name
+		i.modules.enter(i.main)
+	}
 }
 
-func NewInterpreter(errorReporter func(error *RuntimeError)) *Interpreter {
-	i := &Interpreter{errorReporter, NewEnvironment(nil), false, nil, make(map[Expr]int)}
-	i.env.define("clock", &Clock{})
+func NewInterpreter(errorReporter func(error *RuntimeError), options ...Option) *Interpreter {
+	builtins := NewEnvironment(nil)
+	main := &LoxModule{name: "script", globals: NewEnvironment(builtins)}
+	i := &Interpreter{
+		errorReporter: errorReporter,
+		builtins:      builtins,
+		main:          main,
+		module:        main,
+		stdout:        os.Stdout,
+	}
+	for _, option := range options {
+		option(i)
+	}
+	if i.stdin == nil {
+		i.stdin = bufio.NewReader(os.Stdin)
+	}
+
+	i.DefineNative("clock", 0, clock)
+	i.DefineNative("Error", 1, newError)
+	for _, module := range stdlib(i) {
+		i.DefineModule(module)
+	}
 	return i
 }
 
+// DefineNative exposes a go function to scripts as a global function.
+// arity can be Variadic to accept any number of arguments.
+func (i *Interpreter) DefineNative(name string, arity int, fn NativeFn) {
+	i.builtins.define(name, NewNativeFunction(name, arity, fn))
+}
+
+// DefineVariadicNative exposes a go function taking between minArity and maxArity arguments,
+// maxArity can be Variadic to accept any number of arguments after the first minArity.
+func (i *Interpreter) DefineVariadicNative(name string, minArity, maxArity int, fn NativeFn) {
+	i.builtins.define(name, NewVariadicNativeFunction(name, minArity, maxArity, fn))
+}
+
+// DefineModule exposes every member of module under the module's name, e.g. math.sqrt
+func (i *Interpreter) DefineModule(module *NativeModule) {
+	i.builtins.define(module.name, module)
+}
+
 func (i *Interpreter) Interpret(stmts *[]Stmt) (err error) {
 	defer func() {
-		e := recover()
-		if e, ok := e.(*RuntimeError); ok {
-			// err panic occurred
-			err = e
+		if e := recover(); e != nil {
+			runtimeErr, ok := e.(*RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			err = runtimeErr
+			i.trace(runtimeErr)
+			i.calls, i.module = nil, i.main
 
-			i.errorReporter(e)
+			i.errorReporter(runtimeErr)
 		}
 	}()
 
@@ -39,14 +119,95 @@ func (i *Interpreter) Interpret(stmts *[]Stmt) (err error) {
 	return
 }
 
-func (i *Interpreter) execute(stmt Stmt) {
-	if i.breakEncountered {
-		return
+// Execute runs stmts like Interpret but also returns the value of the last statement
+// when it is an expression statement, which is what embedders usually want back.
+func (i *Interpreter) Execute(stmts []Stmt) (value any, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			runtimeErr, ok := e.(*RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			i.trace(runtimeErr)
+			value, err = nil, runtimeErr
+			i.calls, i.module = nil, i.main
+		}
+	}()
+
+	for idx, stmt := range stmts {
+		if expr, ok := stmt.(*Expression); ok && idx == len(stmts)-1 {
+			return i.Evaluate(expr.expression), nil
+		}
+		i.execute(stmt)
 	}
-	if i.returnValue != nil {
-		return
+	return nil, nil
+}
+
+// Call invokes a callable lox value, e.g. a function looked up with Global, from go
+func (i *Interpreter) Call(callee any, arguments ...any) (value any, err error) {
+	module := i.module
+	defer func() {
+		if e := recover(); e != nil {
+			runtimeErr, ok := e.(*RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			i.trace(runtimeErr)
+			value, err = nil, runtimeErr
+			i.calls, i.module = nil, module
+		}
+	}()
+
+	callable, ok := callee.(LoxCallable)
+	if !ok {
+		return nil, &RuntimeError{Message: "can only call functions or classes"}
+	}
+	arguments = bindArguments(callable, arguments, nil, scanner.Token{})
+	i.calls = append(i.calls, call{callee: callable})
+	value = callable.call(i, arguments)
+	i.calls = i.calls[:len(i.calls)-1]
+	return value, nil
+}
+
+// Global looks up a global variable, falling back to the natives
+func (i *Interpreter) Global(name string) (any, bool) {
+	for env := i.main.globals; env != nil; env = env.enclosing {
+		if value, ok := env.values[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// SetGlobal defines or overwrites a global variable
+func (i *Interpreter) SetGlobal(name string, value any) {
+	i.main.globals.define(name, value)
+}
+
+// execute runs stmt and reports how it completed. the statements that can complete abruptly, or that
+// contain ones that can, are run directly so their completion doesn't go through accept's any.
+func (i *Interpreter) execute(stmt Stmt) completion {
+	switch stmt := stmt.(type) {
+	case *Expression:
+		i.Evaluate(stmt.expression)
+		return normal
+	case *If:
+		return i.executeIf(stmt)
+	case *While:
+		return i.executeWhile(stmt)
+	case *Block:
+		return i.executeBlockStmt(stmt)
+	case *Return:
+		return i.executeReturn(stmt)
+	case *Break:
+		return completion{kind: BREAK, label: stmt.label.Lexeme}
+	case *Continue:
+		return completion{kind: CONTINUE, label: stmt.label.Lexeme}
+	case *Try:
+		return i.executeTry(stmt)
 	}
 	stmt.accept(i)
+	return normal
 }
 
 func (i *Interpreter) Evaluate(expr Expr) any {
@@ -56,10 +217,6 @@ func (i *Interpreter) Evaluate(expr Expr) any {
 	return expr.accept(i)
 }
 
-func (i *Interpreter) resolveLocal(expr Expr, depth int) {
-	i.locals[expr] = depth
-}
-
 func (i *Interpreter) VisitLiteralExpr(expr *Literal) any {
 	return expr.value
 }
@@ -69,22 +226,6 @@ func (i *Interpreter) VisitGroupingExpr(expr *Grouping) any {
 }
 
 func (i *Interpreter) VisitUnaryExpr(expr *Unary) any {
-	// TODO: investigate cleaner way for incrementing/decrementing
-	if variable, ok := expr.right.(*Variable); ok {
-		value := i.env.get(variable.name)
-		if value == nil {
-			panic(&RuntimeError{fmt.Sprintf("%s is declared but not initialized", variable.name.Lexeme), variable.name})
-		}
-		if i, ok := value.(float64); ok {
-			if expr.operator.Type == scanner.INCREMENT {
-				return i + 1
-			} else {
-				return i - 1
-			}
-		} else {
-			panic(&RuntimeError{fmt.Sprintf("%s is not a number", variable.name.Lexeme), variable.name})
-		}
-	}
 	right := i.Evaluate(expr.right)
 	switch expr.operator.Type {
 	case scanner.MINUS:
@@ -98,54 +239,47 @@ func (i *Interpreter) VisitUnaryExpr(expr *Unary) any {
 func (i *Interpreter) VisitBinaryExpr(expr *Binary) any {
 	left := i.Evaluate(expr.left)
 	right := i.Evaluate(expr.right)
+	if expr.operator.Type == scanner.COMMA {
+		return right
+	}
+	return binary(expr.operator, left, right)
+}
 
-	switch expr.operator.Type {
+// binary applies a comparison or arithmetic operator, it's shared by binary and compound assignment expressions
+func binary(operator scanner.Token, left, right any) any {
+	switch operator.Type {
 	case scanner.EQUAL_EQUAL:
 		return isEqual(left, right)
 	case scanner.BANG_EQUAL:
 		return !isEqual(left, right)
 	case scanner.GREATER:
-		return checkNumber(left, expr.operator) > checkNumber(right, expr.operator)
+		return checkNumber(left, operator) > checkNumber(right, operator)
 	case scanner.GREATER_EQUAL:
-		return checkNumber(left, expr.operator) >= checkNumber(right, expr.operator)
+		return checkNumber(left, operator) >= checkNumber(right, operator)
 	case scanner.LESS:
-		return checkNumber(left, expr.operator) < checkNumber(right, expr.operator)
+		return checkNumber(left, operator) < checkNumber(right, operator)
 	case scanner.LESS_EQUAL:
-		return checkNumber(left, expr.operator) <= checkNumber(right, expr.operator)
+		return checkNumber(left, operator) <= checkNumber(right, operator)
 	case scanner.MINUS:
-		return checkNumber(left, expr.operator) - checkNumber(right, expr.operator)
+		return checkNumber(left, operator) - checkNumber(right, operator)
 	case scanner.STAR:
-		return checkNumber(left, expr.operator) * checkNumber(right, expr.operator)
+		return checkNumber(left, operator) * checkNumber(right, operator)
 	case scanner.SLASH:
-		right := checkNumber(right, expr.operator)
+		right := checkNumber(right, operator)
 		if right == 0 {
-			panicWithToken(DivisionByZeroError, expr.operator)
+			panicWithToken(DivisionByZeroError, operator)
 		}
-		return checkNumber(left, expr.operator) / right
+		return checkNumber(left, operator) / right
 	case scanner.MODULO:
-		right := checkNumber(right, expr.operator)
+		right := checkNumber(right, operator)
 		if right == 0 {
-			panicWithToken(DivisionByZeroError, expr.operator)
+			panicWithToken(DivisionByZeroError, operator)
 		}
-		return math.Mod(checkNumber(left, expr.operator), right)
+		return math.Mod(checkNumber(left, operator), right)
 	case scanner.PLUS:
-		if isNumber(left) && isNumber(right) {
-			return checkNumber(left, expr.operator) + checkNumber(right, expr.operator)
-		} else if isString(left) && isString(right) {
-			return checkString(left, expr.operator) + checkString(right, expr.operator)
-		} else {
-			if isNumber(left) && isString(right) {
-				return fmt.Sprintf("%v", checkNumber(left, expr.operator)) + checkString(right, expr.operator)
-			}
-			if isString(left) && isNumber(right) {
-				return checkString(left, expr.operator) + fmt.Sprintf("%v", checkNumber(right, expr.operator))
-			}
-			panicWithToken(OnlyStringOrNumberError, expr.operator)
-		}
-	case scanner.COMMA:
-		return right
+		return add(left, right, operator)
 	}
-	panicWithToken(UnknownOperatorError, expr.operator)
+	panicWithToken(UnknownOperatorError, operator)
 	return nil
 }
 
@@ -177,22 +311,23 @@ func (i *Interpreter) VisitLogicalExpr(expr *Logical) any {
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *Variable) any {
-	x := i.lookUpVariable(expr.name, expr)
-	return x
-	// value := i.env.get(expr.name)
-	// if value == nil {
-	// 	panic(&RuntimeError{fmt.Sprintf("%s is declared but not initialized", expr.name.Lexeme), expr.name})
-	// }
-	// return value
-}
-
-func (i *Interpreter) lookUpVariable(name scanner.Token, expr Expr) any {
-	if depth, ok := i.locals[expr]; ok {
-		return i.env.getAt(depth, name.Lexeme)
-	} else {
-		return i.env.get(name)
+	return i.lookUpVariable(expr.name, expr.binding)
+}
+
+func (i *Interpreter) lookUpVariable(name scanner.Token, b binding) any {
+	if b.resolved {
+		return i.frame.ancestor(b.depth).slots[b.slot]
 	}
+	return i.module.globals.get(name)
+}
 
+// define binds a declared name in the current frame, or as a global when it was declared at the top level
+func (i *Interpreter) define(name scanner.Token, b binding, value any) {
+	if b.resolved {
+		i.frame.slots[b.slot] = value
+	} else {
+		i.module.globals.declare(name, value, false)
+	}
 }
 
 func (i *Interpreter) VisitVarStmt(stmt *Var) any {
@@ -200,7 +335,11 @@ func (i *Interpreter) VisitVarStmt(stmt *Var) any {
 	if stmt.initializer != nil {
 		value = i.Evaluate(stmt.initializer)
 	}
-	i.env.define(stmt.name.Lexeme, value)
+	if stmt.constant && !stmt.binding.resolved {
+		i.module.globals.declare(stmt.name, value, true)
+		return nil
+	}
+	i.define(stmt.name, stmt.binding, value)
 	return nil
 }
 
@@ -209,157 +348,500 @@ func (i *Interpreter) VisitExpressionStmt(stmt *Expression) any {
 }
 
 func (i *Interpreter) VisitIfStmt(stmt *If) any {
+	return i.execute(stmt)
+}
+
+func (i *Interpreter) executeIf(stmt *If) completion {
 	flag := isTruthy(i.Evaluate(stmt.condition))
 	if flag {
-		i.execute(stmt.trueBranch)
+		return i.execute(stmt.trueBranch)
 	} else if stmt.falseBranch != nil {
-		i.execute(stmt.falseBranch)
+		return i.execute(stmt.falseBranch)
 	}
-	return nil
+	return normal
 }
 
 func (i *Interpreter) VisitPrintStmt(stmt *Print) any {
 	value := i.Evaluate(stmt.expression)
-	fmt.Println(value)
+	fmt.Fprintln(i.stdout, value)
 	return nil
 }
 
 func (i *Interpreter) VisitWhileStmt(stmt *While) any {
+	return i.execute(stmt)
+}
+
+func (i *Interpreter) executeWhile(stmt *While) completion {
 	for isTruthy(i.Evaluate(stmt.condition)) {
-		i.execute(stmt.body)
-		if i.breakEncountered {
-			i.breakEncountered = false
-			return nil
+		c := i.execute(stmt.body)
+		switch c.kind {
+		case BREAK:
+			if c.targets(stmt) {
+				return normal
+			}
+			return c
+		case CONTINUE:
+			if !c.targets(stmt) {
+				return c
+			}
+		case RETURN:
+			return c
 		}
+		i.Evaluate(stmt.increment)
 	}
-	return nil
+	return normal
 }
 
 func (i *Interpreter) VisitAssignExpr(expr *Assign) any {
 	value := i.Evaluate(expr.value)
-	if depth, ok := i.locals[expr]; ok {
-		i.env.assignAt(depth, expr.name, value)
+	i.assign(expr.name, expr.binding, value)
+	return value
+}
+
+func (i *Interpreter) assign(name scanner.Token, b binding, value any) {
+	if b.resolved {
+		i.frame.ancestor(b.depth).slots[b.slot] = value
 	} else {
-		i.env.assign(expr.name, value)
+		i.module.globals.assign(name, value)
 	}
-	return value
+}
+
+func (i *Interpreter) VisitCompoundAssignExpr(expr *CompoundAssign) any {
+	_, new := i.update(expr.target, expr.operator, func(value any) any {
+		return binary(expr.binaryOperator(), value, i.Evaluate(expr.value))
+	})
+	return new
+}
+
+func (i *Interpreter) VisitUpdateExpr(expr *Update) any {
+	old, new := i.update(expr.target, expr.operator, func(value any) any {
+		return step(value, expr.operator)
+	})
+	if expr.prefix {
+		return new
+	}
+	return old
+}
+
+// update replaces the value of target, a variable, field or indexed element, with modify(value).
+// the object and index of the target are evaluated only once.
+func (i *Interpreter) update(target Expr, operator scanner.Token, modify func(value any) any) (old, new any) {
+	switch target := target.(type) {
+	case *Variable:
+		old = i.lookUpVariable(target.name, target.binding)
+		new = modify(old)
+		i.assign(target.name, target.binding, new)
+	case *Get:
+		object := i.Evaluate(target.instance)
+		old = i.getProperty(object, target.name)
+		new = modify(old)
+		i.setProperty(object, target.name, new)
+	case *Index:
+		object, index := i.Evaluate(target.object), i.Evaluate(target.index)
+		old = i.getIndex(object, index, target.bracket)
+		new = modify(old)
+		i.setIndex(object, index, new, target.bracket)
+	default:
+		panic(&RuntimeError{Message: invalidTarget(operator), Token: operator})
+	}
+	return old, new
+}
+
+// invalidTarget is the error for assigning through operator to something that isn't assignable
+func invalidTarget(operator scanner.Token) string {
+	return fmt.Sprintf("invalid target for '%s', only variables, fields and indexed elements can be assigned to", operator.Lexeme)
+}
+
+// step is the result of incrementing or decrementing value
+func step(value any, operator scanner.Token) float64 {
+	n, ok := value.(float64)
+	if !ok {
+		panic(&RuntimeError{Message: fmt.Sprintf("operand of '%s' must be a number", operator.Lexeme), Token: operator})
+	}
+	if operator.Type == scanner.INCREMENT {
+		return n + 1
+	}
+	return n - 1
 }
 
 func (i *Interpreter) VisitCallExpr(expr *Call) any {
 	callee := i.Evaluate(expr.callee)
 
-	arguments := []any{}
-
+	positional := make([]any, 0, len(expr.arguments))
+	var named []namedArgument
 	for _, arg := range expr.arguments {
-		if f, ok := arg.(*Function); ok {
-			arguments = append(arguments, &LoxFunction{declaration: f, closure: i.env})
-		} else {
-			arguments = append(arguments, i.Evaluate(arg))
+		value := i.Evaluate(arg.value)
+		switch {
+		case arg.isNamed():
+			named = append(named, namedArgument{arg.name, value})
+		case arg.isSpread():
+			positional = append(positional, spreadList(value, arg.spread)...)
+		default:
+			positional = append(positional, value)
 		}
 	}
 
+	// the common call, positional arguments matching the parameters of a plain function, skips binding
+	if function, ok := callee.(*LoxFunction); ok && named == nil && len(positional) == len(function.declaration.params) && function.declaration.plain() {
+		i.calls = append(i.calls, call{callee: function, paren: expr.paren})
+		result := function.call(i, positional)
+		i.calls = i.calls[:len(i.calls)-1]
+		return result
+	}
 	if callable, ok := callee.(LoxCallable); ok {
-		if len(arguments) != callable.arity() {
-			panic(&RuntimeError{fmt.Sprintf("expected %d arguments but got %d", callable.arity(), len(arguments)), expr.paren})
+		arguments := bindArguments(callable, positional, named, expr.paren)
+		if native, ok := callable.(*NativeFunction); ok {
+			return native.callAt(i, arguments, expr.paren)
 		}
-		return callable.call(i, arguments)
+		i.calls = append(i.calls, call{callee: callable, paren: expr.paren})
+		result := callable.call(i, arguments)
+		i.calls = i.calls[:len(i.calls)-1]
+		return result
 	} else {
-		panic(&RuntimeError{"can only call functions or classes", expr.paren})
+		panic(&RuntimeError{Message: "can only call functions or classes", Token: expr.paren})
 	}
 }
 
 func (i *Interpreter) VisitGetExpr(expr *Get) any {
-	instance := i.Evaluate(expr.instance)
-
-	if loxInstance, ok := instance.(*LoxInstance); ok {
-		return loxInstance.get(expr.name)
+	return i.getProperty(i.Evaluate(expr.instance), expr.name)
+}
+
+func (i *Interpreter) getProperty(instance any, name scanner.Token) any {
+	switch object := instance.(type) {
+	case *LoxInstance:
+		return object.get(name)
+	case *LoxClass:
+		return object.get(name)
+	case *LoxList:
+		return object.get(name)
+	case *LoxMap:
+		return object.get(name)
+	case *NativeModule:
+		return object.get(name)
+	case *LoxError:
+		return object.get(name)
+	case *LoxModule:
+		return object.get(name)
+	case string:
+		return stringMethod(object, name)
 	}
-	panic(&RuntimeError{"only instances have properties", expr.name})
+	panic(&RuntimeError{Message: "only instances have properties", Token: name})
 }
 
 func (i *Interpreter) VisitSetExpr(expr *Set) any {
-	instance := i.Evaluate(expr.object)
+	object := i.Evaluate(expr.object)
+	value := i.Evaluate(expr.value)
+	i.setProperty(object, expr.name, value)
+	return value
+}
+
+func (i *Interpreter) setProperty(instance any, name scanner.Token, value any) {
+	switch object := instance.(type) {
+	case *LoxInstance:
+		object.set(name, value)
+	case *LoxClass:
+		object.set(name, value)
+	default:
+		panic(&RuntimeError{Message: "only instances have fields", Token: name})
+	}
+}
+
+func (i *Interpreter) VisitInterpolationExpr(expr *Interpolation) any {
+	var s strings.Builder
+	for _, part := range expr.parts {
+		s.WriteString(stringify(i.Evaluate(part)))
+	}
+	return s.String()
+}
 
-	if loxInstance, ok := instance.(*LoxInstance); ok {
-		value := i.Evaluate(expr.value)
-		loxInstance.set(expr.name, value)
-		return value
+func (i *Interpreter) VisitListExpr(expr *List) any {
+	elements := make([]any, 0, len(expr.elements))
+	for _, element := range expr.elements {
+		elements = append(elements, i.Evaluate(element))
 	}
+	return NewLoxList(elements)
+}
 
-	panic(&RuntimeError{"only instances have fields", expr.name})
+func (i *Interpreter) VisitMapExpr(expr *Map) any {
+	m := NewLoxMap()
+	for idx := range expr.keys {
+		key := i.Evaluate(expr.keys[idx])
+		m.setAt(key, i.Evaluate(expr.values[idx]))
+	}
+	return m
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *Index) any {
+	object := i.Evaluate(expr.object)
+	index := i.Evaluate(expr.index)
+	return i.getIndex(object, index, expr.bracket)
+}
+
+func (i *Interpreter) getIndex(object, index any, bracket scanner.Token) any {
+	switch object := object.(type) {
+	case *LoxList:
+		return object.getAt(index, bracket)
+	case *LoxMap:
+		return object.getAt(index, bracket)
+	case string:
+		return stringAt(object, index, bracket)
+	}
+	panic(&RuntimeError{Message: "only lists, maps and strings can be indexed", Token: bracket})
+}
+
+func (i *Interpreter) VisitIndexSetExpr(expr *IndexSet) any {
+	object := i.Evaluate(expr.object)
+	index := i.Evaluate(expr.index)
+	value := i.Evaluate(expr.value)
+	i.setIndex(object, index, value, expr.bracket)
+	return value
+}
+
+func (i *Interpreter) setIndex(object, index, value any, bracket scanner.Token) {
+	switch object := object.(type) {
+	case *LoxList:
+		object.setAt(index, value, bracket)
+	case *LoxMap:
+		object.setAt(index, value)
+	default:
+		panic(&RuntimeError{Message: "only lists and maps support index assignment", Token: bracket})
+	}
 }
 
 func (i *Interpreter) VisitThisExpr(expr *This) any {
-	return i.lookUpVariable(expr.keyword, expr)
+	return i.lookUpVariable(expr.keyword, expr.binding)
+}
+
+func (i *Interpreter) VisitSuperExpr(expr *Super) any {
+	superclass := i.frame.ancestor(expr.depth).slots[expr.slot].(*LoxClass)
+	// "this" is always bound alone one scope inside the scope holding "super"
+	instance := i.frame.ancestor(expr.depth - 1).slots[0].(*LoxInstance)
+
+	method := superclass.findMethod(expr.method.Lexeme)
+	if method == nil {
+		panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", expr.method.Lexeme), Token: expr.method})
+	}
+	return method.bind(instance)
 }
 
 func (i *Interpreter) VisitReturnStmt(stmt *Return) any {
+	return i.execute(stmt)
+}
+
+func (i *Interpreter) executeReturn(stmt *Return) completion {
 	var value any
 	if stmt.value != nil {
 		value = i.Evaluate(stmt.value)
 	}
-	i.returnValue = value
-	return nil
+	return completion{kind: RETURN, value: value}
 }
 
 func (i *Interpreter) VisitBlockStmt(block *Block) any {
+	return i.execute(block)
+}
+
+func (i *Interpreter) executeBlockStmt(block *Block) completion {
 	if len(block.statements) > 0 {
-		env := NewEnvironment(i.env)
-		i.executeBlock(block.statements, env)
+		f := i.frame
+		if block.slots > 0 {
+			f = newFrame(block.slots, i.frame)
+		}
+		return i.executeBlock(block.statements, f)
 	}
-	return nil
+	return normal
 }
 
 func (i *Interpreter) VisitBreakStatement(stmt *Break) any {
-	i.breakEncountered = true
+	return i.execute(stmt)
+}
+
+func (i *Interpreter) VisitContinueStmt(stmt *Continue) any {
+	return i.execute(stmt)
+}
+
+func (i *Interpreter) VisitThrowStmt(stmt *Throw) any {
+	throw(i.Evaluate(stmt.value), stmt.keyword)
 	return nil
 }
 
+func (i *Interpreter) VisitTryStmt(stmt *Try) any {
+	return i.execute(stmt)
+}
+
+// executeTry runs the finally clause however the try and catch clauses end, a break or return
+// in it overrides theirs and an error they raised is raised again once it's done
+func (i *Interpreter) executeTry(stmt *Try) completion {
+	c, err := i.try(stmt.body, nil)
+	if err != nil && stmt.catchBody != nil {
+		f := newFrame(1, i.frame)
+		f.slots[0] = err.caught()
+		c, err = i.try(stmt.catchBody, f)
+	}
+	if stmt.finallyBody != nil {
+		if f := i.execute(stmt.finallyBody); f.kind != NORMAL {
+			return f
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// try executes block, in frame f when it's not nil, recovering from the runtime error it raises if any
+func (i *Interpreter) try(block *Block, f *frame) (c completion, err *RuntimeError) {
+	calls, module := len(i.calls), i.module
+	defer func() {
+		if e := recover(); e != nil {
+			runtimeErr, ok := e.(*RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			// executeBlock already restored the frame while unwinding
+			i.trace(runtimeErr)
+			i.calls, i.module, err = i.calls[:calls], module, runtimeErr
+		}
+	}()
+	if f != nil {
+		return i.executeBlock([]Stmt{block}, f), nil
+	}
+	return i.execute(block), nil
+}
+
+func (i *Interpreter) VisitImportStmt(stmt *Import) any {
+	module := i.modules.load(stmt.path, i.module.path, i.builtins, i.runModule)
+	if len(stmt.names) == 0 {
+		i.define(stmt.alias, stmt.binding, module)
+		return nil
+	}
+	for idx, name := range stmt.names {
+		i.define(name, stmt.bindings[idx], module.get(name))
+	}
+	return nil
+}
+
+// runModule executes the top level of an imported module, it's a call in stack traces like the VM's script frames
+func (i *Interpreter) runModule(stmts []Stmt, module *LoxModule, path scanner.Token) {
+	prevModule, prevFrame := i.module, i.frame
+	defer func() { i.frame = prevFrame }()
+	i.module, i.frame = module, nil
+	i.calls = append(i.calls, call{module: module, paren: path})
+	for _, stmt := range stmts {
+		i.execute(stmt)
+	}
+	i.calls = i.calls[:len(i.calls)-1]
+	i.module = prevModule
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *Lambda) any {
+	return &LoxFunction{declaration: expr.function, closure: i.frame, module: i.module}
+}
+
 func (i *Interpreter) VisitFunctionStmt(stmt *Function) any {
-	f := &LoxFunction{declaration: stmt, closure: i.env}
-	i.env.define(stmt.name.Lexeme, f)
+	f := &LoxFunction{declaration: stmt, closure: i.frame, module: i.module}
+	i.define(stmt.name, stmt.binding, f)
 	return nil
 }
 
 func (i *Interpreter) VisitClassStmt(stmt *Class) any {
-	i.env.define(stmt.name.Lexeme, nil)
+	var superclass *LoxClass
+	if stmt.superclass != nil {
+		klass, ok := i.Evaluate(stmt.superclass).(*LoxClass)
+		if !ok {
+			panic(&RuntimeError{Message: "superclass must be a class", Token: stmt.superclass.name})
+		}
+		superclass = klass
+	}
+
+	i.define(stmt.name, stmt.binding, nil)
+
+	if superclass != nil {
+		i.frame = newFrame(1, i.frame)
+		i.frame.slots[0] = superclass
+	}
 
 	methods := make(map[string]*LoxFunction)
+	staticMethods := make(map[string]*LoxFunction)
 	for _, method := range stmt.methods {
-		f := &LoxFunction{declaration: method, closure: i.env}
-		methods[method.name.Lexeme] = f
+		f := &LoxFunction{declaration: method, closure: i.frame, module: i.module}
+		if method.functionType == STATIC_METHOD {
+			staticMethods[method.name.Lexeme] = f
+		} else {
+			methods[method.name.Lexeme] = f
+		}
 	}
 
-	loxClass := &LoxClass{name: stmt.name.Lexeme, methods: methods}
+	loxClass := NewLoxClass(stmt.name.Lexeme, superclass, methods, staticMethods)
 
-	i.env.assign(stmt.name, loxClass)
+	if superclass != nil {
+		i.frame = i.frame.enclosing
+	}
+
+	i.define(stmt.name, stmt.binding, loxClass)
+
+	for _, field := range stmt.staticFields {
+		loxClass.set(field.name, i.Evaluate(field.initializer))
+	}
 
 	return nil
 }
 
-func (i *Interpreter) executeBlock(stmts []Stmt, env *Environment) any {
-	prevEnv := i.env
+// executeBlock runs stmts in frame f, stopping at the first statement that doesn't complete normally
+func (i *Interpreter) executeBlock(stmts []Stmt, f *frame) completion {
+	prevFrame := i.frame
 
 	defer func() {
-		i.env = prevEnv
+		i.frame = prevFrame
 	}()
 
-	i.env = env
+	i.frame = f
 
 	for _, stmt := range stmts {
-		i.execute(stmt)
-		if i.returnValue != nil {
-			v := i.returnValue
-			i.returnValue = nil
-			return v
+		if c := i.execute(stmt); c.kind != NORMAL {
+			return c
+		}
+	}
+	return normal
+}
+
+// evaluateDefaults gives the parameters of function left out by the caller their default value.
+// defaults are evaluated in the function's frame so they can use the parameters before them.
+func (i *Interpreter) evaluateDefaults(function *Function, f *frame) {
+	prevFrame := i.frame
+	defer func() {
+		i.frame = prevFrame
+	}()
+	i.frame = f
+
+	for slot, value := range function.defaults {
+		if value != nil && f.slots[slot] == absent {
+			f.slots[slot] = i.Evaluate(value)
+		}
+	}
+}
+
+// add implements '+' for both backends, numbers are added and anything else is concatenated as strings
+func add(left, right any, operator scanner.Token) any {
+	if isNumber(left) && isNumber(right) {
+		return checkNumber(left, operator) + checkNumber(right, operator)
+	} else if isString(left) && isString(right) {
+		return checkString(left, operator) + checkString(right, operator)
+	} else {
+		if isNumber(left) && isString(right) {
+			return fmt.Sprintf("%v", checkNumber(left, operator)) + checkString(right, operator)
 		}
+		if isString(left) && isNumber(right) {
+			return checkString(left, operator) + fmt.Sprintf("%v", checkNumber(right, operator))
+		}
+		panicWithToken(OnlyStringOrNumberError, operator)
 	}
 	return nil
 }
 
+// panicWithToken raises a copy of e at token. e is one of the shared errors of error.go and is never
+// raised itself, the trace of the copy and the runtime raising it are its own.
 func panicWithToken(e *RuntimeError, token scanner.Token) {
-	e.Token = token
-	panic(e)
+	panic(&RuntimeError{Message: e.Message, Token: token})
 }
 
 func checkNumber(value any, token scanner.Token) (f float64) {
@@ -376,6 +858,11 @@ func isNumber(value any) bool {
 	return ok
 }
 
+// stringify formats a value the way print does
+func stringify(value any) string {
+	return fmt.Sprint(value)
+}
+
 func checkString(value any, token scanner.Token) (s string) {
 	if value_s, ok := value.(string); ok {
 		s = value_s
@@ -410,3 +897,61 @@ func isTruthy(value any) bool {
 	}
 	return true
 }
+
+// call is a lox call being executed, paren is where it was called from
+type call struct {
+	callee LoxCallable
+	// module is set instead of callee when the call runs the top level of an imported module, paren is then the import
+	module *LoxModule
+	paren  scanner.Token
+}
+
+// trace records the lox call stack and the module err was raised in, they're still there while it unwinds.
+// errors raised again keep the stack of the place they were first raised from.
+func (i *Interpreter) trace(err *RuntimeError) {
+	if err.Stack != nil {
+		return
+	}
+	err.Stack = make([]StackFrame, 0, len(i.calls)+1)
+	if i.module != i.main {
+		err.File, err.Source = i.module.path, i.module.source
+	}
+	line := err.Token.Line
+	for idx := len(i.calls) - 1; idx >= 0; idx-- {
+		frame := StackFrame{Function: "script"}
+		if module := i.calls[idx].module; module != nil {
+			frame.File = module.path
+		} else {
+			frame = callFrameOf(i.calls[idx].callee)
+		}
+		frame.Line = line
+		err.Stack = append(err.Stack, frame)
+		line = i.calls[idx].paren.Line
+	}
+	// calls made from go don't have a script around them
+	if line != 0 {
+		err.Stack = append(err.Stack, StackFrame{Function: "script", File: i.main.path, Line: line})
+	}
+}
+
+// callFrameOf names callee in stack traces, calling a class runs its initializer
+func callFrameOf(callee LoxCallable) StackFrame {
+	switch callee := callee.(type) {
+	case *LoxFunction:
+		return StackFrame{Function: functionName(callee.declaration.name.Lexeme), Class: callee.declaration.class, File: callee.module.path}
+	case *LoxClass:
+		frame := StackFrame{Function: "init", Class: callee.name}
+		if init := callee.findMethod("init"); init != nil {
+			frame.File = init.module.path
+		}
+		return frame
+	}
+	return StackFrame{Function: "<native>"}
+}
+
+func functionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
@@ -1,12 +1,78 @@
 package ast
 
 import (
+	"bytes"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
+// backend runs a parsed script, every interpreter test goes through both the tree-walker and the VM
+type backend interface {
+	run(stmts []Stmt, reporter diagnostics.Reporter)
+	env() *Environment
+}
+
+type treeWalker struct{ *Interpreter }
+
+func (w treeWalker) run(stmts []Stmt, reporter diagnostics.Reporter) {
+	NewResolver(reporter).Resolve(&stmts)
+	w.Interpret(&stmts)
+}
+
+func (w treeWalker) env() *Environment {
+	return w.Interpreter.main.globals
+}
+
+type bytecode struct{ *VM }
+
+func (b bytecode) run(stmts []Stmt, reporter diagnostics.Reporter) {
+	NewResolver(reporter).Resolve(&stmts)
+	b.Interpret(NewCompiler(reporter).Compile(stmts))
+}
+
+func (b bytecode) env() *Environment {
+	return b.interpreter.main.globals
+}
+
+type backendKind struct {
+	name string
+	new  func(errorReporter func(*RuntimeError), options ...Option) backend
+}
+
+var backends = []backendKind{
+	{"tree-walker", func(errorReporter func(*RuntimeError), options ...Option) backend {
+		return treeWalker{NewInterpreter(errorReporter, options...)}
+	}},
+	{"vm", func(errorReporter func(*RuntimeError), options ...Option) backend {
+		return bytecode{NewVM(errorReporter, options...)}
+	}},
+}
+
+// run parses src and runs it on a new backend of kind, static errors fail the test.
+// it returns what the script printed and the runtime error that stopped it, if any.
+func run(t *testing.T, kind backendKind, src string, options ...Option) (string, *RuntimeError) {
+	t.Helper()
+	var out bytes.Buffer
+	var err *RuntimeError
+	reporter := diagnostics.ReporterFunc(func(d *diagnostics.Diagnostic) { t.Errorf("[line %d] Error: %s", d.Span.Line, d.Message) })
+	stmts, _ := NewParser(scanner.NewScanner(src, reporter).ScanTokens(), reporter).Parse()
+	kind.new(func(e *RuntimeError) { err = e }, append([]Option{WithStdout(&out)}, options...)...).run(stmts, reporter)
+	return out.String(), err
+}
+
+// withGlobals hands the globals of the script to the test, to check the variables it declared
+func withGlobals(globals **Environment) Option {
+	return func(i *Interpreter) {
+		*globals = i.main.globals
+	}
+}
+
 func TestInterpreter(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -39,21 +105,754 @@ func TestInterpreter(t *testing.T) {
 		}
 		`, map[string]any{"i": float64(15), "j": float64(3)}},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			s := scanner.NewScanner(tt.input, func(int, string) {})
-			tokens := s.ScanTokens()
-			parser := NewParser(tokens, func(l int, w, m string) { log.Println("[line ", l, "] Error", w, ": ", m) })
-			stmts, _ := parser.Parse()
-			interpreter := NewInterpreter(func(err *RuntimeError) { log.Println(err.Message, "[line ", err.Token.Line, "]") })
-			interpreter.Interpret(&stmts)
-			environment := interpreter.env.values
-			for k, v := range environment {
-				if v != tt.expected[k] {
-					t.Errorf("Interpreter.interpret(%v). got = %s, want %s", tt.input, environment, tt.expected)
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				s := scanner.NewScanner(tt.input, &diagnostics.Collector{})
+				tokens := s.ScanTokens()
+				reporter := diagnostics.ReporterFunc(func(d *diagnostics.Diagnostic) { log.Println("[line ", d.Span.Line, "] Error: ", d.Message) })
+				parser := NewParser(tokens, reporter)
+				stmts, _ := parser.Parse()
+				interpreter := backend.new(func(err *RuntimeError) { log.Println(err.Message, "[line ", err.Token.Line, "]") })
+				interpreter.run(stmts, reporter)
+				environment := interpreter.env().values
+				for k, v := range environment {
+					if v != tt.expected[k] {
+						t.Errorf("Interpreter.interpret(%v). got = %s, want %s", tt.input, environment, tt.expected)
+					}
+				}
+			})
+		}
+	}
+
+}
+
+func TestInterpreterGlobals(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]any
+	}{
+		{"inheritance", `
+		class A {
+			name() { return "A"; }
+			greet() { return "hello " + this.name(); }
+		}
+		class B < A {
+			name() { return "B"; }
+			greet() { return super.greet() + "!"; }
+		}
+		class C < B {}
+		var greeting = C().greet();
+		var base = A().greet();
+		`, map[string]any{"greeting": "hello B!", "base": "hello A"}},
+		{"static methods and fields", `
+		class Math {
+			static pi = 3;
+			static square(n) { return n * n; }
+			class cube(n) { return Math.square(n) * n; }
+		}
+		class Geometry < Math {}
+		Math.e = 2;
+		var square = Math.square(3);
+		var cube = Geometry.cube(2);
+		var area = Math.pi * Math.square(2) + Math.e;
+		`, map[string]any{"square": float64(9), "cube": float64(8), "area": float64(14)}},
+		{"lists", `
+		var xs = [1, 2, 3,];
+		xs[0] = 10;
+		xs.push(4);
+		xs.insert(0, 0);
+		var last = xs.pop();
+		var length = xs.len();
+		var middle = xs.slice(1, 3);
+		var first = xs[0] + xs[1] + middle[1];
+		var nested = [[1], [2, 3]][1][0];
+		`, map[string]any{"last": float64(4), "length": float64(4), "first": float64(12), "nested": float64(2)}},
+		{"maps", `
+		var m = {"a": 1, "b": 2, 3: "three",};
+		m["c"] = 4;
+		m["a"] = 10;
+		var removed = m.remove("b");
+		var keys = m.keys();
+		var order = keys[0] + keys[2];
+		var length = m.len();
+		var hasB = m.has("b");
+		var sum = m["a"] + m["c"] + m.values()[2];
+		var empty = {}.len();
+		`, map[string]any{"removed": float64(2), "order": "ac", "length": float64(3), "hasB": false, "sum": float64(18), "empty": float64(0)}},
+		{"string methods", `
+		var s = "  Héllo, wörld  ".trim();
+		var length = s.len();
+		var shout = s.upper() + s.lower();
+		var parts = s.split(", ");
+		var joined = "-".join([parts[1], 1, nil]);
+		var found = s.indexOf("wö") + s.indexOf("x");
+		var has = s.contains("llo") and (s.startsWith("Hé") and !"".contains("a"));
+		var replaced = s.replace("l", "L");
+		var sub = s.substr(1, 4) + s.substr(s.len(), 0);
+		var chars = s[1] + s[8] + "日本".split("")[1];
+		`, map[string]any{"length": float64(12), "shout": "HÉLLO, WÖRLDhéllo, wörld", "joined": "wörld-1-<nil>",
+			"found": float64(6), "has": true, "replaced": "HéLLo, wörLd", "sub": "éllo", "chars": "éö本"}},
+		{"native modules", `
+		var root = math.sqrt(16);
+		var biggest = math.max(3, 7, 5);
+		var shout = string.upper("hi") + string.len("héllo");
+		var parsed = string.number(" 4.5 ") * 2;
+		var clock = "shadowed";
+		var elapsed = time.clock() >= 0;
+		`, map[string]any{"root": float64(4), "biggest": float64(7), "shout": "HI5", "parsed": float64(9), "clock": "shadowed", "elapsed": true}},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				var globals *Environment
+				if _, err := run(t, backend, tt.input, withGlobals(&globals)); err != nil {
+					t.Errorf("%s [line %d]", err.Message, err.Token.Line)
+				}
+				for k, v := range tt.expected {
+					if got := globals.values[k]; got != v {
+						t.Errorf("Interpreter.interpret(%v). %s = %v, want %v", tt.input, k, got, v)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDefineNative(t *testing.T) {
+	input := `
+	var total = sum(1, 2, 3);
+	var twice = double(21);
+	var rounded = round(2.5) + round(2.25, 1);
+	`
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			// the VM defines its natives on the interpreter it wraps, so an option reaches both backends
+			natives := func(i *Interpreter) {
+				i.DefineNative("sum", Variadic, func(arguments []any) (any, error) {
+					total := 0.0
+					for _, argument := range arguments {
+						total += argument.(float64)
+					}
+					return total, nil
+				})
+				i.DefineNative("double", 1, func(arguments []any) (any, error) {
+					return arguments[0].(float64) * 2, nil
+				})
+				i.DefineVariadicNative("round", 1, 2, func(arguments []any) (any, error) {
+					scale := 1.0
+					if len(arguments) == 2 {
+						scale = math.Pow(10, arguments[1].(float64))
+					}
+					return math.Round(arguments[0].(float64)*scale) / scale, nil
+				})
+			}
+			var globals *Environment
+			if _, err := run(t, backend, input, natives, withGlobals(&globals)); err != nil {
+				t.Errorf("%s [line %d]", err.Message, err.Token.Line)
+			}
+
+			if got := globals.values["total"]; got != float64(6) {
+				t.Errorf("sum(1, 2, 3) = %v, want 6", got)
+			}
+			if got := globals.values["twice"]; got != float64(42) {
+				t.Errorf("double(21) = %v, want 42", got)
+			}
+			if got := globals.values["rounded"]; got != 5.3 {
+				t.Errorf("round(2.5) + round(2.25, 1) = %v, want 5.3", got)
+			}
+		})
+	}
+}
+
+func TestInterpreterOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"print", `print 1; print "two"; print [3];`, "1\ntwo\n[3]\n"},
+		{"interpolation", `
+		var name = "Ada";
+		var items = ["a", "b"];
+		print "hi ${name}, you have ${items.len() + 1} items: ${items}";
+		print "${nil} ${1.5} ${true} ${"nested ${name.upper()}"}";
+		print "tab\there\n\"quoted\" \u{e9} \${name}";
+		`, "hi Ada, you have 3 items: [a, b]\n<nil> 1.5 true nested ADA\ntab\there\n\"quoted\" é ${name}\n"},
+		{"io natives", `io.write("a", 1); io.writeln(); io.writeln("b");`, "a 1\nb\n"},
+		{"closures", `
+		fun counter() {
+			var i = 0;
+			fun count() { i = i + 1; return i; }
+			return count;
+		}
+		var c = counter();
+		c();
+		print c();
+		`, "2\n"},
+		{"closures per iteration", `
+		var fs = [];
+		for (var i = 0; i < 5; i++) {
+			var j = i;
+			fun f() { return j; }
+			fs.push(f);
+			if (i == 2) break;
+		}
+		print fs.len();
+		print fs[0]() + fs[1]() + fs[2]();
+		`, "3\n3\n"},
+		{"control flow", `
+		var total = 0;
+		var n = 0;
+		while (true) {
+			n = n + 1;
+			if (n % 2 == 0) total = total + n; else total = total - 1;
+			if (n >= 6) break;
+		}
+		print total;
+		print n > 3 ? "big" : "small";
+		print nil or "default";
+		print false and 1;
+		print (1, 2, 3);
+		`, "9\nbig\ndefault\nfalse\n3\n"},
+		{"break, continue and return", `
+		fun nested() { if (true) { while (true) { return 1; } } return 2; }
+		fun early() { return nil; print "unreachable"; }
+		fun bare() { return; print "unreachable"; }
+		fun falsy() { { return false; } }
+		print nested();
+		print early();
+		print bare();
+		print falsy();
+		for (var i = 0; i < 5; i = i + 1) {
+			if (i == 1) continue;
+			if (i == 3) break;
+			print i;
+		}
+		outer: for (var i = 0; i < 3; i = i + 1) {
+			var j = 0;
+			while (true) {
+				j = j + 1;
+				if (j == 2) continue outer;
+				if (i == 2) break outer;
+				print i * 10 + j;
+			}
+		}
+		`, "1\n<nil>\n<nil>\nfalse\n0\n2\n1\n11\n"},
+		{"classes", `
+		class Point {
+			init(x, y) { this.x = x; this.y = y; }
+			sum() { return this.x + this.y; }
+		}
+		class Point3 < Point {
+			init(x, y, z) { super.init(x, y); this.z = z; }
+			sum() { return super.sum() + this.z; }
+		}
+		var p = Point3(1, 2, 3);
+		var sum = p.sum;
+		print sum();
+		print p.init(0, 0, 1) == p;
+		print p;
+		`, "6\ntrue\nPoint3 instance\n"},
+		{"recursion and anonymous functions", `
+		fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); }
+		fun apply(f, x) { return f(x); }
+		print apply(fun (n) { return fib(n) * 2; }, 15);
+		`, "1220\n"},
+		{"increment and decrement", `
+		var i = 0;
+		print i++;
+		print i;
+		print ++i;
+		print --i + i--;
+		print i;
+		class Point { init() { this.x = 1; } }
+		var p = Point();
+		print p.x++ + ++p.x;
+		var calls = 0;
+		fun point() { calls++; return p; }
+		point().x--;
+		print p.x;
+		var xs = [5, 6];
+		var at = 0;
+		print xs[at++]++;
+		print xs[0] + at;
+		var m = {"n": 1};
+		++m["n"];
+		print m["n"];
+		fun closure() { var n = 0; return () => ++n; }
+		var next = closure();
+		next();
+		print next() + calls;
+		`, "0\n1\n2\n2\n0\n4\n2\n5\n7\n2\n3\n"},
+		{"compound assignment", `
+		var n = 10;
+		n += 5;
+		n -= 3;
+		n *= 2;
+		n /= 4;
+		print n;
+		n %= 4;
+		print n;
+		var s = "a";
+		s += "b";
+		print s;
+		class Box { init() { this.v = 1; } }
+		var box = Box();
+		var calls = 0;
+		fun get() { calls++; return box; }
+		print get().v += 2;
+		var xs = [1, 2];
+		var i = 0;
+		xs[i++] *= 10;
+		print xs[0] + xs[1] + i + calls;
+		var m = {"k": "x"};
+		m["k"] += "y";
+		print m["k"];
+		`, "6\n2\nab\n3\n14\nxy\n"},
+		{"lambdas", `
+		var add = (a, b) => a + b;
+		var twice = fun (f, x) { return f(f(x)); };
+		print twice((x) => add(x, 1), 1);
+		fun adder(n) { return (x) => { var sum = x + n; return sum; }; }
+		print adder(10)(5);
+		class Counter {
+			init() { this.count = 0; this.bump = () => { this.count = this.count + 1; return this; }; }
+		}
+		var c = Counter();
+		print c.bump().bump().count;
+		var curry = (a) => (b) => a * b;
+		print curry(3)(4);
+		fun () { print "immediately"; }();
+		print fun () {};
+		`, "3\n15\n2\n12\nimmediately\n<fn>\n"},
+		{"parameters and arguments", `
+		fun greet(name, greeting = "hello", end = "!") { return greeting + " " + name + end; }
+		print greet("ann");
+		print greet("bob", "hi");
+		print greet("cy", end: "?");
+		print greet(greeting: "hey", name: "di");
+		print greet(...["eve", "yo"]);
+		fun sum(first, ...rest) {
+			for (var i = 0; i < rest.len(); i++) first += rest[i];
+			return first;
+		}
+		print sum(1);
+		print sum(1, 2, ...[3, 4], 5);
+		fun collect(...xs) { return xs; }
+		print collect();
+		fun window(start, end = start + 10) { return end - start; }
+		print window(5);
+		fun fresh(x, xs = []) { xs.push(x); return xs.len(); }
+		fresh(1);
+		print fresh(2);
+		var scale = (x, by = 2) => x * by;
+		print scale(4) + scale(4, by: 3);
+		class Point {
+			init(x = 0, y = 0) { this.x = x; this.y = y; }
+			moved(dx = 0, dy = 0) { return Point(this.x + dx, this.y + dy); }
+		}
+		var p = Point(y: 2).moved(dy: 1);
+		print p.x + p.y;
+		print math.max(...[3, 9], 2);
+		`, "hello ann!\nhi bob!\nhello cy?\nhey di!\nyo eve!\n1\n15\n[]\n10\n1\n20\n3\n9\n"},
+		{"constants", `
+		const limit = 3;
+		fun under(x) { return x < limit; }
+		print under(2);
+		{
+			const limit = "shadowed";
+			var copy = limit;
+			copy += "!";
+			print copy;
+		}
+		fun counter() {
+			const step = 2;
+			var n = 0;
+			return () => { n += step; return n; };
+		}
+		var next = counter();
+		next();
+		print next();
+		var total = limit + 7;
+		total++;
+		print total;
+		`, "true\nshadowed!\n4\n11\n"},
+		{"exceptions", `
+		fun check(x) {
+			if (x > 2) throw Error("too big");
+			return x;
+		}
+		try { print check(1); check(3); print "unreachable"; } catch (e) { print e.message + " at " + e.line; }
+		try { 1 / 0; } catch (e) { print e.message; }
+		try { throw "raw"; } catch (e) { print e; } finally { print "finally"; }
+		fun cleanup() { try { return "returned"; } finally { print "cleaned up"; } }
+		print cleanup();
+		fun override() { try { throw "lost"; } finally { return "override"; } }
+		print override();
+		for (var i = 0; i < 3; i = i + 1) {
+			try { if (i == 1) continue; if (i == 2) break; print i; } finally { print "after " + i; }
+		}
+		try {
+			try { throw "inner"; } catch (e) { throw e + " again"; } finally { print "inner finally"; }
+		} catch (e) { print e; }
+		`, "1\ntoo big at 3\ndivision by zero\nraw\nfinally\ncleaned up\nreturned\noverride\n0\nafter 0\nafter 1\nafter 2\ninner finally\ninner again\n"},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				got, err := run(t, backend, tt.input)
+				if err != nil {
+					t.Errorf("%s [line %d]", err.Message, err.Token.Line)
+				}
+				if got != tt.expected {
+					t.Errorf("Interpreter.interpret(%v) printed %q, want %q", tt.input, got, tt.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestUncaughtException(t *testing.T) {
+	input := `
+fun inner() {
+	throw Error("boom");
+}
+fun outer() {
+	inner();
+}
+outer();
+`
+	expected := []StackFrame{{Function: "inner", Line: 3}, {Function: "outer", Line: 6}, {Function: "script", Line: 8}}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			_, got := run(t, backend, input)
+			if got == nil {
+				t.Fatal("expected an uncaught exception")
+			}
+			if got.Message != "uncaught exception: Error: boom" || got.Token.Line != 3 {
+				t.Errorf("got %q at line %d, want %q at line 3", got.Message, got.Token.Line, "uncaught exception: Error: boom")
+			}
+			if len(got.Stack) != len(expected) {
+				t.Fatalf("got stack %v, want %v", got.Stack, expected)
+			}
+			for i, frame := range expected {
+				if got.Stack[i] != frame {
+					t.Errorf("frame %d = %v, want %v", i, got.Stack[i], frame)
+				}
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorStack(t *testing.T) {
+	input := `
+class Shape {
+	init(side) { this.side = side; }
+	area() { return this.side * scale; }
+}
+fun main() {
+	return Shape(2).area();
+}
+main();
+`
+	expected := []string{"at Shape.area (shapes.lox:4)", "at main (shapes.lox:7)", "at script (shapes.lox:9)"}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			_, got := run(t, backend, input, WithFileName("shapes.lox"))
+			if got == nil {
+				t.Fatal("expected a runtime error")
+			}
+			if len(got.Stack) != len(expected) {
+				t.Fatalf("got stack %v, want %v", got.Stack, expected)
+			}
+			for i, frame := range expected {
+				if got.Stack[i].String() != frame {
+					t.Errorf("frame %d = %q, want %q", i, got.Stack[i], frame)
+				}
+			}
+		})
+	}
+}
+
+func TestArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{"fun f(a, b = 1) {}\nf();", "expected 1 to 2 arguments but got 0", 2, 3},
+		{"fun f(a, ...rest) {}\nf();", "expected at least 1 arguments but got 0", 2, 3},
+		{"fun f(a) {}\nf(1, ...[2]);", "expected 1 arguments but got 2", 2, 12},
+		{"fun f(a) {}\nf(b: 1);", "no parameter named 'b'", 2, 3},
+		{"fun f(a, ...rest) {}\nf(1, rest: 2);", "no parameter named 'rest'", 2, 6},
+		{"fun f(a) {}\nf(1, a: 2);", "multiple values for parameter 'a'", 2, 6},
+		{"fun f(a, b) {}\nf(b: 1);", "missing argument for parameter 'a'", 2, 7},
+		{"fun f(a) {}\nf(...a);", "undefined variable 'a'", 2, 6},
+		{"fun f(a) {}\nf(...1);", "can only spread lists", 2, 3},
+		{"class A {}\nA(x: 1);", "no parameter named 'x'", 2, 3},
+		{"clock(x: 1);", "no parameter named 'x'", 1, 7},
+		{"math.min();", "expected at least 1 arguments but got 0", 1, 10},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.message, func(t *testing.T) {
+				_, got := run(t, backend, tt.input)
+				if got == nil {
+					t.Fatal("expected a runtime error")
+				}
+				if got.Message != tt.message || got.Token.Line != tt.line || got.Token.Column != tt.column {
+					t.Errorf("got %q at %d:%d, want %q at %d:%d", got.Message, got.Token.Line, got.Token.Column, tt.message, tt.line, tt.column)
+				}
+			})
+		}
+	}
+}
+
+func TestConstantAssignment(t *testing.T) {
+	// locals are rejected by the resolver before anything runs
+	input := `
+{
+	const a = 1;
+	a = 2;
+	a++;
+	--a;
+	a *= 3;
+	fun f() { a = 4; }
+	{ var a = 5; a = 6; }
+}
+fun g() { const b = 1; { var b = 2; } b -= 1; }
+`
+	var collector diagnostics.Collector
+	stmts, _ := NewParser(scanner.NewScanner(input, &collector).ScanTokens(), &collector).Parse()
+	NewResolver(&collector).Resolve(&stmts)
+	lines := []int{4, 5, 6, 7, 8, 11}
+	got := collector.Diagnostics()
+	if len(got) != len(lines) {
+		t.Fatalf("got %d diagnostics, want %d", len(got), len(lines))
+	}
+	for i, line := range lines {
+		if got[i].Code != diagnostics.ConstantAssignment || got[i].Span.Line != line {
+			t.Errorf("diagnostic %d = %s at line %d, want %s at line %d", i, got[i].Code, got[i].Span.Line, diagnostics.ConstantAssignment, line)
+		}
+	}
+
+	// globals can be declared by other scripts or REPL lines, so they're checked at runtime
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"const a = 1;\na = 2;", 2, 1},
+		{"const a = 1;\nfun f() { a += 1; }\nf();", 2, 11},
+		{"const a = 1;\nprint a;\n++a;", 3, 3},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.input, func(t *testing.T) {
+				var globals *Environment
+				_, got := run(t, backend, tt.input, withGlobals(&globals))
+				if got == nil {
+					t.Fatal("expected a runtime error")
+				}
+				if got.Message != "can't assign to constant 'a'" || got.Token.Line != tt.line || got.Token.Column != tt.column {
+					t.Errorf("got %q at %d:%d, want assignment error at %d:%d", got.Message, got.Token.Line, got.Token.Column, tt.line, tt.column)
+				}
+				if value := globals.values["a"]; value != float64(1) {
+					t.Errorf("a = %v after failed assignment, want 1", value)
+				}
+			})
+		}
+	}
+}
+
+func TestConstantRedeclaration(t *testing.T) {
+	// locals are rejected by the resolver, shadowing a constant in an inner scope is fine
+	input := `
+{
+	const a = 1;
+	var a = 2;
+	fun a() {}
+	{ var a = 3; }
+}
+fun f() { const b = 1; class b {} }
+`
+	var collector diagnostics.Collector
+	stmts, _ := NewParser(scanner.NewScanner(input, &collector).ScanTokens(), &collector).Parse()
+	NewResolver(&collector).Resolve(&stmts)
+	lines := []int{4, 5, 8}
+	got := collector.Diagnostics()
+	if len(got) != len(lines) {
+		t.Fatalf("got %d diagnostics, want %d", len(got), len(lines))
+	}
+	for i, line := range lines {
+		if got[i].Code != diagnostics.ConstantRedeclared || got[i].Span.Line != line {
+			t.Errorf("diagnostic %d = %s at line %d, want %s at line %d", i, got[i].Code, got[i].Span.Line, diagnostics.ConstantRedeclared, line)
+		}
+	}
+
+	// globals are checked at runtime
+	tests := []struct {
+		input  string
+		column int
+	}{
+		{"const K = 1;\nvar K = 2;", 5},
+		{"const K = 1;\nconst K = 2;", 7},
+		{"const K = 1;\nfun K() {}", 5},
+		{"const K = 1;\nclass K {}", 7},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.input, func(t *testing.T) {
+				var globals *Environment
+				_, got := run(t, backend, tt.input, withGlobals(&globals))
+				if got == nil {
+					t.Fatal("expected a runtime error")
 				}
+				if got.Message != "can't redeclare constant 'K'" || got.Token.Line != 2 || got.Token.Column != tt.column {
+					t.Errorf("got %q at %d:%d, want redeclaration error at 2:%d", got.Message, got.Token.Line, got.Token.Column, tt.column)
+				}
+				if value := globals.values["K"]; value != float64(1) {
+					t.Errorf("K = %v after failed redeclaration, want 1", value)
+				}
+			})
+		}
+	}
+}
+
+func TestRuntimeErrorStackNotShared(t *testing.T) {
+	// both errors come from the same shared division by zero error, the second one gets its own trace
+	input := `fun f() { try { 1 / 0; } catch (e) {} }
+fun g() { f(); }
+g();
+
+2 % 0;`
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			_, got := run(t, backend, input, WithFileName("a.lox"))
+			if got == nil {
+				t.Fatal("expected a runtime error")
+			}
+			if len(got.Stack) != 1 || got.Stack[0].String() != "at script (a.lox:5)" || got.Token.Line != 5 {
+				t.Errorf("got %q at line %d with stack %v, want it at line 5 with stack [at script (a.lox:5)]", got.Message, got.Token.Line, got.Stack)
+			}
+			if got == DivisionByZeroError {
+				t.Error("raised the shared division by zero error itself")
 			}
 		})
 	}
+}
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	lib := t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "math.lox"):    `var factor = 2; fun scale(x) { return x * factor; }`,
+		filepath.Join(dir, "counter.lox"): `var count = 0; fun bump() { count = count + 1; return count; }`,
+		filepath.Join(dir, "a.lox"):       `import "b" as b;`,
+		filepath.Join(dir, "b.lox"):       `import "a.lox" as a;`,
+		filepath.Join(dir, "main.lox"):    `import "back" as back;`,
+		filepath.Join(dir, "back.lox"):    `import "main" as main;`,
+		filepath.Join(lib, "strings.lox"): `var greeting = "hello";`,
+	}
+	for name, source := range files {
+		if err := os.WriteFile(name, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
 
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]any
+		err      string
+	}{
+		{"import as", `var factor = 100; import "math" as m; var r = m.scale(3);`, map[string]any{"r": float64(6)}, ""},
+		{"from import", `from "math.lox" import scale, factor; var r = scale(4);`, map[string]any{"r": float64(8), "factor": float64(2)}, ""},
+		{"modules run once", `import "counter" as a; import "counter" as b; a.bump(); var r = b.bump();`, map[string]any{"r": float64(2)}, ""},
+		{"import in catch", `var r; try { throw 3; } catch (e) { import "math" as m; r = m.scale(e); }`, map[string]any{"r": float64(6)}, ""},
+		{"search path", `from "strings" import greeting;`, map[string]any{"greeting": "hello"}, ""},
+		{"missing member", `from "math" import nope;`, nil, "module math has no member nope"},
+		{"missing module", `import "nope" as nope;`, nil, "can't find module 'nope'"},
+		{"cycle", `import "a" as a;`, nil, "import cycle: " + filepath.Join(dir, "a.lox") + " -> " + filepath.Join(dir, "b.lox") + " -> " + filepath.Join(dir, "a.lox")},
+		{"cycle through the entry script", `import "back" as back;`, nil, "import cycle: " + filepath.Join(dir, "main.lox") + " -> " + filepath.Join(dir, "back.lox") + " -> " + filepath.Join(dir, "main.lox")},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				var globals *Environment
+				_, got := run(t, backend, tt.input, WithFileName(filepath.Join(dir, "main.lox")), WithSearchPath(lib), withGlobals(&globals))
+				if tt.err != "" {
+					if got == nil || got.Message != tt.err {
+						t.Fatalf("got error %v, want %q", got, tt.err)
+					}
+					return
+				}
+				if got != nil {
+					t.Fatalf("unexpected error: %s", got.Message)
+				}
+				for name, want := range tt.expected {
+					if value, _ := globals.values[name]; value != want {
+						t.Errorf("%s = %v, want %v", name, value, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestModuleRuntimeError(t *testing.T) {
+	dir := t.TempDir()
+	lib := "var v = 1;\nfun boom(x) {\n  return x / 0;\n}\n"
+	bad := "var v = 1;\nvar w = v / 0;\n"
+	mid := "var m = 1;\nimport \"bad\" as bad;\n"
+	for name, source := range map[string]string{"lib.lox": lib, "bad.lox": bad, "mid.lox": mid} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := func(function, file string, line int) string {
+		return StackFrame{Function: function, File: filepath.Join(dir, file), Line: line}.String()
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		file   string
+		source string
+		line   int
+		stack  []string
+	}{
+		{"in a function", "from \"lib\" import v;\nimport \"lib\" as lib;\nlib.boom(1);", filepath.Join(dir, "lib.lox"), lib, 3,
+			[]string{at("boom", "lib.lox", 3), at("script", "main.lox", 3)}},
+		{"while importing", "var x = 1;\nimport \"bad\" as bad;", filepath.Join(dir, "bad.lox"), bad, 2,
+			[]string{at("script", "bad.lox", 2), at("script", "main.lox", 2)}},
+		{"while importing through another module", "var x = 1;\n\nimport \"mid\" as mid;", filepath.Join(dir, "bad.lox"), bad, 2,
+			[]string{at("script", "bad.lox", 2), at("script", "mid.lox", 2), at("script", "main.lox", 3)}},
+		{"after a module error was caught", "import \"lib\" as lib;\ntry { lib.boom(1); } catch (e) {}\n1 / 0;", "", "", 3,
+			[]string{at("script", "main.lox", 3)}},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				_, got := run(t, backend, tt.input, WithFileName(filepath.Join(dir, "main.lox")))
+				if got == nil {
+					t.Fatal("expected a runtime error")
+				}
+				if got.File != tt.file || got.Source != tt.source || got.Token.Line != tt.line {
+					t.Errorf("got error in %q at line %d, want it in %q at line %d", got.File, got.Token.Line, tt.file, tt.line)
+				}
+				if len(got.Stack) != len(tt.stack) {
+					t.Fatalf("got stack %v, want %v", got.Stack, tt.stack)
+				}
+				for i, frame := range tt.stack {
+					if got.Stack[i].String() != frame {
+						t.Errorf("frame %d = %q, want %q", i, got.Stack[i], frame)
+					}
+				}
+			})
+		}
+	}
 }
@@ -1,6 +1,116 @@
 package ast
 
+import (
+	"fmt"
+
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// signature is what the arguments of a call are matched against, the VM's callables have one too
+type signature interface {
+	// arity is the least and the most positional arguments accepted, max is Variadic when there's no limit
+	arity() (min, max int)
+	// parameters names the parameters in order, a rest parameter comes last. natives have no names.
+	parameters() []string
+}
+
 type LoxCallable interface {
-	arity() int
+	signature
+	// call gets the arguments bindArguments matched to the parameters
 	call(interpreter *Interpreter, arguments []any) any
 }
+
+// namedArgument is an argument passed as `name: value`
+type namedArgument struct {
+	name  scanner.Token
+	value any
+}
+
+// absentArgument fills the parameters a call left out, they're replaced by their default value when the call starts
+type absentArgument struct{}
+
+var absent = absentArgument{}
+
+// bindArguments matches the arguments of a call to the parameters of callee. the result holds an argument
+// per parameter in order: the ones left out are absent and a rest parameter gets the extra positional
+// arguments as a list. callees without parameter names, like natives, get the positional arguments as they are.
+func bindArguments(callee signature, positional []any, named []namedArgument, paren scanner.Token) []any {
+	minArity, maxArity := callee.arity()
+	// missing arguments may still be passed by name
+	if maxArity != Variadic && len(positional) > maxArity || len(named) == 0 && len(positional) < minArity {
+		panic(&RuntimeError{Message: arityError(minArity, maxArity, len(positional)), Token: paren})
+	}
+	params := callee.parameters()
+	if len(params) == 0 {
+		if len(named) > 0 {
+			panic(&RuntimeError{Message: fmt.Sprintf("no parameter named '%s'", named[0].name.Lexeme), Token: named[0].name})
+		}
+		return positional
+	}
+
+	fixed := len(params)
+	if maxArity == Variadic {
+		fixed--
+	}
+	arguments := make([]any, len(params))
+	for i := range arguments[:fixed] {
+		if i < len(positional) {
+			arguments[i] = positional[i]
+		} else {
+			arguments[i] = absent
+		}
+	}
+	if maxArity == Variadic {
+		rest := []any{}
+		if len(positional) > fixed {
+			rest = append(rest, positional[fixed:]...)
+		}
+		arguments[fixed] = NewLoxList(rest)
+	}
+
+	for _, argument := range named {
+		i := parameterIndex(params[:fixed], argument.name.Lexeme)
+		if i < 0 {
+			panic(&RuntimeError{Message: fmt.Sprintf("no parameter named '%s'", argument.name.Lexeme), Token: argument.name})
+		}
+		if arguments[i] != absent {
+			panic(&RuntimeError{Message: fmt.Sprintf("multiple values for parameter '%s'", argument.name.Lexeme), Token: argument.name})
+		}
+		arguments[i] = argument.value
+	}
+	for i := 0; i < minArity; i++ {
+		if arguments[i] == absent {
+			panic(&RuntimeError{Message: fmt.Sprintf("missing argument for parameter '%s'", params[i]), Token: paren})
+		}
+	}
+	return arguments
+}
+
+func parameterIndex(params []string, name string) int {
+	for i, param := range params {
+		if param == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func arityError(min, max, got int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("expected %d arguments but got %d", min, got)
+	case max == Variadic:
+		return fmt.Sprintf("expected at least %d arguments but got %d", min, got)
+	default:
+		return fmt.Sprintf("expected %d to %d arguments but got %d", min, max, got)
+	}
+}
+
+// spreadList returns the elements of the list spread into a call with '...'
+func spreadList(value any, ellipsis scanner.Token) []any {
+	list, ok := value.(*LoxList)
+	if !ok {
+		panic(&RuntimeError{Message: "can only spread lists", Token: ellipsis})
+	}
+	return list.elements
+}
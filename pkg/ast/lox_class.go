@@ -1,20 +1,44 @@
 package ast
 
+// LoxClass is itself an instance of its metaclass, which holds the static methods.
+// this lets static methods and class level fields reuse the instance get/set machinery.
 type LoxClass struct {
-	name    string
-	methods map[string]*LoxFunction
+	*LoxInstance
+	name       string
+	superclass *LoxClass
+	methods    map[string]*LoxFunction
+}
+
+func NewLoxClass(name string, superclass *LoxClass, methods, staticMethods map[string]*LoxFunction) *LoxClass {
+	var metaSuperclass *LoxClass
+	if superclass != nil && superclass.LoxInstance != nil {
+		metaSuperclass = superclass.LoxInstance.class
+	}
+	metaclass := &LoxClass{name: name + " metaclass", superclass: metaSuperclass, methods: staticMethods}
+
+	klass := &LoxClass{name: name, superclass: superclass, methods: methods}
+	klass.LoxInstance = NewLoxInstance(metaclass)
+	return klass
 }
 
 func (klass *LoxClass) String() string {
 	return klass.name
 }
 
-func (klass *LoxClass) arity() int {
+func (klass *LoxClass) arity() (min, max int) {
 	init := klass.findMethod("init")
 	if init != nil {
 		return init.arity()
 	}
-	return 0
+	return 0, 0
+}
+
+func (klass *LoxClass) parameters() []string {
+	init := klass.findMethod("init")
+	if init != nil {
+		return init.parameters()
+	}
+	return nil
 }
 
 func (klass *LoxClass) call(interpreter *Interpreter, arguments []any) any {
@@ -30,5 +54,8 @@ func (klass *LoxClass) findMethod(name string) *LoxFunction {
 	if m, ok := klass.methods[name]; ok {
 		return m
 	}
+	if klass.superclass != nil {
+		return klass.superclass.findMethod(name)
+	}
 	return nil
 }
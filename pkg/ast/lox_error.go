@@ -0,0 +1,37 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// LoxError is what a catch clause receives for errors raised by the runtime itself,
+// e.g. a division by zero. scripts create their own with the Error native.
+type LoxError struct {
+	message string
+	// line is where the error was raised or first thrown from
+	line int
+}
+
+func NewLoxError(message string) *LoxError {
+	return &LoxError{message: message}
+}
+
+func (e *LoxError) get(name scanner.Token) any {
+	switch name.Lexeme {
+	case "message":
+		return e.message
+	case "line":
+		return float64(e.line)
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
+}
+
+func (e *LoxError) String() string {
+	return "Error: " + e.message
+}
+
+func newError(arguments []any) (any, error) {
+	return NewLoxError(fmt.Sprint(arguments[0])), nil
+}
@@ -2,30 +2,43 @@ package ast
 
 type LoxFunction struct {
 	declaration *Function
-	closure     *Environment
+	closure     *frame
+	// module is where the function was declared, its globals are the ones the function sees
+	module *LoxModule
 }
 
-func (f *LoxFunction) arity() int {
-	return len(f.declaration.params)
+func (f *LoxFunction) arity() (min, max int) {
+	return f.declaration.arity()
+}
+
+func (f *LoxFunction) parameters() []string {
+	return f.declaration.parameters()
 }
 
 func (f *LoxFunction) isConstructor() bool {
-	return f.declaration.name.Lexeme == "init"
+	return f.declaration.functionType != STATIC_METHOD && f.declaration.name.Lexeme == "init"
 }
 
 func (f *LoxFunction) call(interpreter *Interpreter, arguments []any) any {
-	env := NewEnvironment(f.closure)
-	for i, param := range f.declaration.params {
-		env.define(param.Lexeme, arguments[i])
+	// parameters take the first slots of the frame in order
+	env := newFrame(f.declaration.slots, f.closure)
+	copy(env.slots, arguments)
+	// the module isn't restored on errors, whoever recovers from them does it once trace has seen it
+	module := interpreter.module
+	interpreter.module = f.module
+	if !f.declaration.plain() {
+		interpreter.evaluateDefaults(f.declaration, env)
 	}
 	result := interpreter.executeBlock(f.declaration.body, env)
+	interpreter.module = module
 
 	// special handling for calling constructor(init) on a class innstance
 	if f.isConstructor() {
-		return f.closure.getAt(0, "this")
+		return f.closure.slots[0]
 	}
 
-	return result
+	// falling off the end of the body completes normally with a nil value
+	return result.value
 }
 
 func (l LoxFunction) String() string {
@@ -36,7 +49,7 @@ func (l LoxFunction) String() string {
 }
 
 func (f LoxFunction) bind(instance *LoxInstance) *LoxFunction {
-	env := NewEnvironment(f.closure)
-	env.define("this", instance)
-	return &LoxFunction{f.declaration, env}
+	env := newFrame(1, f.closure)
+	env.slots[0] = instance
+	return &LoxFunction{f.declaration, env, f.module}
 }
@@ -27,7 +27,7 @@ func (instance *LoxInstance) get(name scanner.Token) any {
 	if method != nil {
 		return method.bind(instance)
 	}
-	panic(&RuntimeError{fmt.Sprintf("undefined property %s", name.Lexeme), name})
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
 }
 
 func (instance *LoxInstance) set(name scanner.Token, value any) {
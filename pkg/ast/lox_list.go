@@ -0,0 +1,107 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+type LoxList struct {
+	elements []any
+}
+
+func NewLoxList(elements []any) *LoxList {
+	return &LoxList{elements: elements}
+}
+
+func (list *LoxList) String() string {
+	elements := make([]string, len(list.elements))
+	for i, element := range list.elements {
+		elements[i] = fmt.Sprintf("%v", element)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (list *LoxList) getAt(index any, token scanner.Token) any {
+	i, err := checkIndex("list", index, len(list.elements))
+	if err != nil {
+		panic(&RuntimeError{Message: err.Error(), Token: token})
+	}
+	return list.elements[i]
+}
+
+func (list *LoxList) setAt(index any, value any, token scanner.Token) {
+	i, err := checkIndex("list", index, len(list.elements))
+	if err != nil {
+		panic(&RuntimeError{Message: err.Error(), Token: token})
+	}
+	list.elements[i] = value
+}
+
+// checkIndex makes sure index is a whole number in [0, upper) and converts it to an int,
+// what names the index in error messages
+func checkIndex(what string, index any, upper int) (int, error) {
+	f, ok := index.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, fmt.Errorf("%s index must be an integer", what)
+	}
+	if f < 0 || f >= float64(upper) {
+		return 0, fmt.Errorf("%s index %v out of range [0, %d)", what, f, upper)
+	}
+	return int(f), nil
+}
+
+func (list *LoxList) get(name scanner.Token) any {
+	switch name.Lexeme {
+	case "len":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			return float64(len(list.elements)), nil
+		})
+	case "push":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			list.elements = append(list.elements, arguments[0])
+			return nil, nil
+		})
+	case "pop":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			if len(list.elements) == 0 {
+				return nil, fmt.Errorf("can't pop from an empty list")
+			}
+			last := list.elements[len(list.elements)-1]
+			list.elements = list.elements[:len(list.elements)-1]
+			return last, nil
+		})
+	case "insert":
+		return NewNativeFunction(name.Lexeme, 2, func(arguments []any) (any, error) {
+			// inserting right after the last element is allowed
+			i, err := checkIndex("list", arguments[0], len(list.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			list.elements = append(list.elements, nil)
+			copy(list.elements[i+1:], list.elements[i:])
+			list.elements[i] = arguments[1]
+			return nil, nil
+		})
+	case "slice":
+		return NewNativeFunction(name.Lexeme, 2, func(arguments []any) (any, error) {
+			start, err := checkIndex("list", arguments[0], len(list.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			end, err := checkIndex("list", arguments[1], len(list.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("slice start can't be after its end")
+			}
+			elements := make([]any, end-start)
+			copy(elements, list.elements[start:end])
+			return NewLoxList(elements), nil
+		})
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
+}
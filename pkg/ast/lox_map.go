@@ -0,0 +1,89 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// LoxMap keeps its keys in insertion order so iterating over keys() or values() is deterministic.
+// keys are compared with the same rules as isEqual.
+type LoxMap struct {
+	keys    []any
+	entries map[any]any
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{entries: make(map[any]any)}
+}
+
+func (m *LoxMap) String() string {
+	entries := make([]string, len(m.keys))
+	for i, key := range m.keys {
+		entries[i] = fmt.Sprintf("%v: %v", key, m.entries[key])
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (m *LoxMap) getAt(key any, token scanner.Token) any {
+	if value, ok := m.entries[key]; ok {
+		return value
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined key %v", key), Token: token})
+}
+
+func (m *LoxMap) setAt(key any, value any) {
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+}
+
+func (m *LoxMap) remove(key any) any {
+	value, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	delete(m.entries, key)
+	for i, k := range m.keys {
+		if isEqual(k, key) {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return value
+}
+
+func (m *LoxMap) get(name scanner.Token) any {
+	switch name.Lexeme {
+	case "len":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			return float64(len(m.keys)), nil
+		})
+	case "keys":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			keys := make([]any, len(m.keys))
+			copy(keys, m.keys)
+			return NewLoxList(keys), nil
+		})
+	case "values":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			values := make([]any, len(m.keys))
+			for i, key := range m.keys {
+				values[i] = m.entries[key]
+			}
+			return NewLoxList(values), nil
+		})
+	case "has":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			_, ok := m.entries[arguments[0]]
+			return ok, nil
+		})
+	case "remove":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			return m.remove(arguments[0]), nil
+		})
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
+}
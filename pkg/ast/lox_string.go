@@ -0,0 +1,125 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// strings are plain go strings, lengths and indexes count runes so non ascii text works as expected
+
+// stringAt returns the rune at index as a string of its own
+func stringAt(s string, index any, token scanner.Token) any {
+	runes := []rune(s)
+	i, err := checkIndex("string", index, len(runes))
+	if err != nil {
+		panic(&RuntimeError{Message: err.Error(), Token: token})
+	}
+	return string(runes[i])
+}
+
+// stringMethod returns the method name of s bound to it
+func stringMethod(s string, name scanner.Token) any {
+	switch name.Lexeme {
+	case "len":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			return float64(utf8.RuneCountInString(s)), nil
+		})
+	case "upper":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			return strings.ToUpper(s), nil
+		})
+	case "lower":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			return strings.ToLower(s), nil
+		})
+	case "trim":
+		return NewNativeFunction(name.Lexeme, 0, func(arguments []any) (any, error) {
+			return strings.TrimSpace(s), nil
+		})
+	case "split":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			sep, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			// an empty separator splits s into its runes
+			parts := strings.Split(s, sep)
+			elements := make([]any, len(parts))
+			for i, part := range parts {
+				elements[i] = part
+			}
+			return NewLoxList(elements), nil
+		})
+	case "join":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			list, ok := arguments[0].(*LoxList)
+			if !ok {
+				return nil, fmt.Errorf("argument 1 must be a list")
+			}
+			parts := make([]string, len(list.elements))
+			for i, element := range list.elements {
+				parts[i] = fmt.Sprint(element)
+			}
+			return strings.Join(parts, s), nil
+		})
+	case "contains":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			sub, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Contains(s, sub), nil
+		})
+	case "startsWith":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			prefix, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			return strings.HasPrefix(s, prefix), nil
+		})
+	case "indexOf":
+		return NewNativeFunction(name.Lexeme, 1, func(arguments []any) (any, error) {
+			sub, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			i := strings.Index(s, sub)
+			if i < 0 {
+				return float64(-1), nil
+			}
+			return float64(utf8.RuneCountInString(s[:i])), nil
+		})
+	case "replace":
+		return NewNativeFunction(name.Lexeme, 2, func(arguments []any) (any, error) {
+			old, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			replacement, err := stringArg(arguments, 1)
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s, old, replacement), nil
+		})
+	case "substr":
+		// substr(start, length) like javascript, the substring may not run past the end of s
+		return NewNativeFunction(name.Lexeme, 2, func(arguments []any) (any, error) {
+			runes := []rune(s)
+			start, err := checkIndex("string", arguments[0], len(runes)+1)
+			if err != nil {
+				return nil, err
+			}
+			length, ok := arguments[1].(float64)
+			if !ok || length != math.Trunc(length) || length < 0 || length > float64(len(runes)-start) {
+				return nil, fmt.Errorf("substring length must be an integer in [0, %d]", len(runes)-start)
+			}
+			return string(runes[start : start+int(length)]), nil
+		})
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
+}
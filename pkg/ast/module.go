@@ -0,0 +1,128 @@
+package ast
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// LoxModule is a script run by an import. it has globals of its own,
+// which is what the functions it declares see and what importers can reach through it.
+type LoxModule struct {
+	name string
+	path string
+	// source is the code of the module, runtime errors raised in it are quoted from it
+	source  string
+	globals *Environment
+}
+
+func (m *LoxModule) get(name scanner.Token) any {
+	if value, ok := m.globals.values[name.Lexeme]; ok {
+		return value
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("module %s has no member %s", m.name, name.Lexeme), Token: name})
+}
+
+func (m *LoxModule) String() string {
+	return "<module " + m.name + ">"
+}
+
+// modules finds, loads and caches the modules imported by scripts. each file is run once,
+// later imports of it share the same module.
+type modules struct {
+	// searchPath lists the directories searched after the one of the importing file
+	searchPath []string
+	cache      map[string]*LoxModule
+	// loading are the files being run, innermost last, an import of one of them is a cycle
+	loading []string
+}
+
+// WithSearchPath adds directories where imported modules are looked for
+// when they aren't found next to the file importing them
+func WithSearchPath(dirs ...string) Option {
+	return func(i *Interpreter) {
+		i.modules.searchPath = append(i.modules.searchPath, dirs...)
+	}
+}
+
+// load returns the module at path, running it with run the first time it's imported, run gets path as where it's run from.
+// relative paths are looked for next to importer first, then along the search path.
+func (m *modules) load(path scanner.Token, importer string, builtins *Environment, run func(stmts []Stmt, module *LoxModule, path scanner.Token)) *LoxModule {
+	file, ok := m.find(path.Literal.(string), importer)
+	if !ok {
+		panic(&RuntimeError{Message: fmt.Sprintf("can't find module '%s'", path.Literal), Token: path})
+	}
+	// the entry script is cached and loading at once, an import of it is a cycle
+	for i, loading := range m.loading {
+		if loading == file {
+			cycle := append(append([]string{}, m.loading[i:]...), file)
+			panic(&RuntimeError{Message: "import cycle: " + strings.Join(cycle, " -> "), Token: path})
+		}
+	}
+	if module, ok := m.cache[file]; ok {
+		return module
+	}
+
+	source, err := os.ReadFile(file)
+	if err != nil {
+		panic(&RuntimeError{Message: fmt.Sprintf("can't read module '%s': %s", file, err), Token: path})
+	}
+	var collector diagnostics.Collector
+	stmts, _ := NewParser(scanner.NewScanner(string(source), &collector).ScanTokens(), &collector).Parse()
+	NewResolver(&collector).Resolve(&stmts)
+	if collector.HasErrors() {
+		panic(moduleError(file, collector.Diagnostics()[0], path))
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	module := &LoxModule{name: name, path: file, source: string(source), globals: NewEnvironment(builtins)}
+	m.loading = append(m.loading, file)
+	defer func() { m.loading = m.loading[:len(m.loading)-1] }()
+	run(stmts, module, path)
+
+	m.store(file, module)
+	return module
+}
+
+// enter registers the entry script, it's loading for as long as the interpreter runs
+// so importing it back is reported as a cycle instead of running it a second time
+func (m *modules) enter(main *LoxModule) {
+	file := filepath.Clean(main.path)
+	m.loading = append(m.loading, file)
+	m.store(file, main)
+}
+
+func (m *modules) store(file string, module *LoxModule) {
+	if m.cache == nil {
+		m.cache = make(map[string]*LoxModule)
+	}
+	m.cache[file] = module
+}
+
+func (m *modules) find(path, importer string) (string, bool) {
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(filepath.Dir(importer), path)}
+		for _, dir := range m.searchPath {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+	for _, candidate := range candidates {
+		// the extension can be left out
+		for _, file := range []string{candidate, candidate + ".lox"} {
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				return file, true
+			}
+		}
+	}
+	return "", false
+}
+
+// moduleError reports a static error found in an imported module at the import
+func moduleError(file string, d *diagnostics.Diagnostic, path scanner.Token) *RuntimeError {
+	return &RuntimeError{Message: fmt.Sprintf("error in module %s:%d:%d: %s", file, d.Span.Line, d.Span.Column, d.Message), Token: path}
+}
@@ -1,17 +1,116 @@
 package ast
 
-import "time"
+import (
+	"fmt"
 
-type Clock struct{}
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
 
-func (c *Clock) arity() int {
-	return 0
+// Variadic marks a native function that accepts any number of arguments.
+// as a max arity it lifts the limit on how many arguments are accepted.
+const Variadic = -1
+
+// NativeFn is the go implementation behind a NativeFunction.
+// returning a non nil error raises a runtime error at the call site.
+type NativeFn func(arguments []any) (any, error)
+
+type NativeFunction struct {
+	name     string
+	minArity int
+	maxArity int
+	fn       NativeFn
+}
+
+func NewNativeFunction(name string, arity int, fn NativeFn) *NativeFunction {
+	if arity == Variadic {
+		return NewVariadicNativeFunction(name, 0, Variadic, fn)
+	}
+	return NewVariadicNativeFunction(name, arity, arity, fn)
+}
+
+// NewVariadicNativeFunction makes a native that takes between minArity and maxArity arguments,
+// maxArity can be Variadic to accept any number of arguments after the first minArity.
+func NewVariadicNativeFunction(name string, minArity, maxArity int, fn NativeFn) *NativeFunction {
+	return &NativeFunction{name: name, minArity: minArity, maxArity: maxArity, fn: fn}
+}
+
+func (f *NativeFunction) arity() (min, max int) {
+	return f.minArity, f.maxArity
+}
+
+// parameters is empty since natives can't be passed named arguments
+func (f *NativeFunction) parameters() []string {
+	return nil
+}
+
+// call is used when no call site is known, VisitCallExpr goes through callAt
+// so errors point to the closing paren of the call.
+func (f *NativeFunction) call(i *Interpreter, arguments []any) any {
+	return f.callAt(i, arguments, scanner.Token{})
+}
+
+func (f *NativeFunction) callAt(i *Interpreter, arguments []any, paren scanner.Token) any {
+	value, err := f.fn(arguments)
+	if err != nil {
+		panic(&RuntimeError{Message: err.Error(), Token: paren})
+	}
+	return value
+}
+
+func (f NativeFunction) String() string {
+	return "<native fn " + f.name + ">"
+}
+
+// NativeModule groups related natives under a single global name, e.g. math.sqrt
+type NativeModule struct {
+	name    string
+	members map[string]any
+}
+
+func NewNativeModule(name string) *NativeModule {
+	return &NativeModule{name: name, members: make(map[string]any)}
+}
+
+// Define adds a native function to the module. it returns the module to allow chaining.
+func (m *NativeModule) Define(name string, arity int, fn NativeFn) *NativeModule {
+	m.members[name] = NewNativeFunction(m.name+"."+name, arity, fn)
+	return m
+}
+
+// DefineVariadic adds a native function taking between minArity and maxArity arguments to the module,
+// see NewVariadicNativeFunction
+func (m *NativeModule) DefineVariadic(name string, minArity, maxArity int, fn NativeFn) *NativeModule {
+	m.members[name] = NewVariadicNativeFunction(m.name+"."+name, minArity, maxArity, fn)
+	return m
+}
+
+// DefineValue adds a constant value to the module, e.g. math.pi
+func (m *NativeModule) DefineValue(name string, value any) *NativeModule {
+	m.members[name] = value
+	return m
+}
+
+func (m *NativeModule) get(name scanner.Token) any {
+	if member, ok := m.members[name.Lexeme]; ok {
+		return member
+	}
+	panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s on module %s", name.Lexeme, m.name), Token: name})
+}
+
+func (m NativeModule) String() string {
+	return "<native module " + m.name + ">"
 }
 
-func (c *Clock) call(i *Interpreter, a []any) any {
-	return float64(time.Now().UnixNano() / int64(time.Millisecond))
+func numberArg(arguments []any, index int) (float64, error) {
+	if f, ok := arguments[index].(float64); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("argument %d must be a number", index+1)
 }
 
-func (c Clock) String() string {
-	return "<native fn>"
+func stringArg(arguments []any, index int) (string, error) {
+	if s, ok := arguments[index].(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("argument %d must be a string", index+1)
 }
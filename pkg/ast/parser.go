@@ -3,47 +3,48 @@ package ast
 import (
 	"fmt"
 
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
 type Parser struct {
-	tokens         []scanner.Token
-	current        int
-	error_reporter func(int, string, string)
-	// used to track how many loops we are parsing currently to report error if user is breaking outside loop
-	loops int
+	tokens   []scanner.Token
+	current  int
+	reporter diagnostics.Reporter
+	hadError bool
+	// labels of the loops being parsed, innermost last. unlabeled loops have an empty label.
+	// used to report break and continue outside a loop or naming a loop they aren't in
+	loops []string
 }
 
-func NewParser(tokens []scanner.Token, error_reporter func(int, string, string)) *Parser {
-	return &Parser{tokens: tokens, error_reporter: error_reporter}
+func NewParser(tokens []scanner.Token, reporter diagnostics.Reporter) *Parser {
+	return &Parser{tokens: tokens, reporter: reporter}
 }
 
+// Parse keeps going after a syntax error so every error in the source gets reported,
+// statements that failed to parse are left out and ParseErrorObj is returned.
 func (p *Parser) Parse() (stmts []Stmt, err error) {
-	defer func() (err error) {
-		e := recover()
-		if e, ok := e.(error); ok {
-			// err panic occurred
-			err = e
-		}
-		return
-	}()
-
 	stmts = make([]Stmt, 0, len(p.tokens))
 
 	for !p.isAtEnd() {
-		stmt := p.declaration()
-		stmts = append(stmts, stmt)
+		if stmt := p.declaration(); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
 	}
 
+	if p.hadError {
+		err = ParseErrorObj
+	}
 	return
 }
 
-func (p *Parser) declaration() Stmt {
+func (p *Parser) declaration() (stmt Stmt) {
 	defer func() {
 		e := recover()
 		if e != nil {
-			if _, ok := e.(ParseError); ok {
+			if _, ok := e.(*ParseError); ok {
 				p.synchronize()
+				stmt = nil
 				return
 			} else {
 				panic(e)
@@ -55,63 +56,197 @@ func (p *Parser) declaration() Stmt {
 		return p.classDeclaration()
 	}
 
-	if p.match(scanner.FUN) {
-		return p.function("function", false)
+	// 'fun' not followed by a name starts an anonymous function in an expression statement
+	if p.check(scanner.FUN) && p.checkNext(scanner.IDENTIFIER) {
+		p.advance()
+		return p.function("function")
 	}
 
 	if p.match(scanner.VAR) {
 		return p.varDeclaration()
 	}
+
+	if p.match(scanner.CONST) {
+		return p.constDeclaration()
+	}
+
+	if p.match(scanner.IMPORT) {
+		return p.importDeclaration()
+	}
+
+	// "from" is only special at the start of a selective import, it's still usable as a name
+	if p.check(scanner.IDENTIFIER) && p.peek().Lexeme == "from" && p.checkNext(scanner.STRING) {
+		return p.fromImportDeclaration()
+	}
 	return p.statement()
 }
 
+func (p *Parser) importDeclaration() Stmt {
+	stmt := &Import{keyword: p.previous()}
+	stmt.path = p.consume(scanner.STRING, "expect module path after import")
+	if !p.check(scanner.IDENTIFIER) || p.peek().Lexeme != "as" {
+		p.error(p.peek(), diagnostics.ExpectedToken, "expect 'as' after module path")
+		panic(ParseErrorObj)
+	}
+	p.advance()
+	stmt.alias = p.consume(scanner.IDENTIFIER, "expect module name after 'as'")
+	p.consume(scanner.SEMICOLON, "expect ';' after import")
+	return stmt
+}
+
+func (p *Parser) fromImportDeclaration() Stmt {
+	stmt := &Import{keyword: p.advance()}
+	stmt.path = p.advance()
+	p.consume(scanner.IMPORT, "expect 'import' after module path")
+	stmt.names = append(stmt.names, p.consume(scanner.IDENTIFIER, "expect name to import"))
+	for p.match(scanner.COMMA) {
+		stmt.names = append(stmt.names, p.consume(scanner.IDENTIFIER, "expect name to import"))
+	}
+	p.consume(scanner.SEMICOLON, "expect ';' after import")
+	return stmt
+}
+
 func (p *Parser) classDeclaration() Stmt {
 	name := p.consume(scanner.IDENTIFIER, "expect class name")
-	p.consume(scanner.LEFT_BRACE, "expect '{' after class name")
+
+	var superclass *Variable
+	if p.match(scanner.LESS) {
+		p.consume(scanner.IDENTIFIER, "expect superclass name")
+		superclass = &Variable{name: p.previous()}
+	}
+
+	p.consume(scanner.LEFT_BRACE, "expect '{' before class body")
 
 	methods := make([]*Function, 0)
+	staticFields := make([]*Var, 0)
 
 	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
-		m := p.function("method", false)
-		if function, ok := m.(*Function); ok {
+		// class level members can be prefixed with either "static" or "class"
+		if p.match(scanner.STATIC, scanner.CLASS) {
+			if p.checkNext(scanner.EQUAL) {
+				field := p.consume(scanner.IDENTIFIER, "expect static field name")
+				p.consume(scanner.EQUAL, "expect '=' after static field name")
+				initializer := p.expression()
+				p.consume(scanner.SEMICOLON, "expect ';' after static field declaration")
+				staticFields = append(staticFields, &Var{name: field, initializer: initializer})
+				continue
+			}
+			function := p.function("static method")
+			function.functionType = STATIC_METHOD
 			methods = append(methods, function)
+			continue
 		}
+		methods = append(methods, p.function("method"))
 	}
 
 	p.consume(scanner.RIGHT_BRACE, "expect '}' at end of class body")
 
-	return &Class{name, methods}
-}
-
-func (p *Parser) function(kind string, argument bool) Stmt {
-	var name scanner.Token
-	// only anonymous functions allowed to not have function name.
-	// anonymous functions can be assigned to a variable or passed directly as function argument
-	if p.check(scanner.IDENTIFIER) {
-		name = p.consume(scanner.IDENTIFIER, fmt.Sprintf("expect %s name", kind))
-	} else if !argument {
-		p.error_reporter(p.peek().Line, "", fmt.Sprintf("expect %s name", kind))
+	for _, method := range methods {
+		method.class = name.Lexeme
 	}
+	return &Class{name: name, superclass: superclass, methods: methods, staticFields: staticFields}
+}
 
+func (p *Parser) function(kind string) *Function {
+	name := p.consume(scanner.IDENTIFIER, fmt.Sprintf("expect %s name", kind))
 	p.consume(scanner.LEFT_PAREN, fmt.Sprintf("expect '(' after %s name", kind))
+	function := &Function{name: name}
+	p.parameters(kind, function)
+	p.consume(scanner.LEFT_BRACE, fmt.Sprintf("expect '{' before %s body", kind))
+	function.body = p.functionBody()
+	return function
+}
 
-	var params []scanner.Token
-
+// parameters parses the parameter list of function after its '(', up to and including the ')'.
+// parameters with a default value come after the required ones and a rest parameter comes last.
+func (p *Parser) parameters(kind string, function *Function) {
 	if !p.check(scanner.RIGHT_PAREN) {
-		if len(params) > 255 {
-			p.error_reporter(p.peek().Line, "", "can't have more than 255 parameters")
-		}
-		params = append(params, p.consume(scanner.IDENTIFIER, "expect parameter name"))
+		p.parameter(function)
 		for p.match(scanner.COMMA) {
-			params = append(params, p.consume(scanner.IDENTIFIER, "expect parameter name"))
+			if len(function.params) >= 255 {
+				p.report(diagnostics.Error(diagnostics.TooManyParameters, p.peek().Span(), "can't have more than 255 parameters"))
+			}
+			if function.rest {
+				rest := function.params[len(function.params)-1]
+				p.report(diagnostics.Error(diagnostics.InvalidParameter, rest.Span(), "rest parameter must be the last parameter"))
+			}
+			p.parameter(function)
 		}
 	}
 	p.consume(scanner.RIGHT_PAREN, fmt.Sprintf("expect ')' after %s parameters", kind))
+}
 
-	p.consume(scanner.LEFT_BRACE, fmt.Sprintf("expect '{' before %s body", kind))
+func (p *Parser) parameter(function *Function) {
+	if p.match(scanner.ELLIPSIS) {
+		function.params = append(function.params, p.consume(scanner.IDENTIFIER, "expect rest parameter name after '...'"))
+		function.defaults = append(function.defaults, nil)
+		function.rest = true
+		return
+	}
+	param := p.consume(scanner.IDENTIFIER, "expect parameter name")
+	var value Expr
+	if p.match(scanner.EQUAL) {
+		value = p.expression(true)
+	} else if len(function.defaults) > 0 && function.defaults[len(function.defaults)-1] != nil {
+		p.report(diagnostics.Error(diagnostics.InvalidParameter, param.Span(), fmt.Sprintf("parameter '%s' needs a default value", param.Lexeme)).
+			WithNote("parameters after one with a default value must have defaults too"))
+	}
+	function.params = append(function.params, param)
+	function.defaults = append(function.defaults, value)
+}
+
+// functionBody parses the block of a function after its '{'
+func (p *Parser) functionBody() []Stmt {
+	// loops around the function don't extend into its body
+	enclosingLoops := p.loops
+	p.loops = nil
 	body := p.block()
+	p.loops = enclosingLoops
+	return body
+}
 
-	return &Function{name: name, params: params, body: body}
+// lambda parses an anonymous function after its 'fun'
+func (p *Parser) lambda() Expr {
+	keyword := p.previous()
+	p.consume(scanner.LEFT_PAREN, "expect '(' after 'fun'")
+	function := &Function{}
+	p.parameters("function", function)
+	p.consume(scanner.LEFT_BRACE, "expect '{' before function body")
+	function.body = p.functionBody()
+	return &Lambda{keyword: keyword, function: function}
+}
+
+// arrow parses `(params) => body` after its '(', the body is either a block
+// or a single expression whose value is returned
+func (p *Parser) arrow() Expr {
+	paren := p.previous()
+	function := &Function{}
+	p.parameters("function", function)
+	arrow := p.consume(scanner.ARROW, "expect '=>' after parameters")
+	if p.match(scanner.LEFT_BRACE) {
+		function.body = p.functionBody()
+	} else {
+		function.body = []Stmt{&Return{keyword: arrow, value: p.expression(true)}}
+	}
+	return &Lambda{keyword: paren, function: function}
+}
+
+// isArrow looks for '=>' right after the ')' matching the '(' at the current token,
+// default values can be any expression so the whole parenthesized list is skipped
+func (p *Parser) isArrow() bool {
+	depth := 0
+	for i := p.current; p.tokens[i].Type != scanner.EOF; i++ {
+		switch p.tokens[i].Type {
+		case scanner.LEFT_PAREN:
+			depth++
+		case scanner.RIGHT_PAREN:
+			depth--
+			if depth == 0 {
+				return p.tokens[i+1].Type == scanner.ARROW
+			}
+		}
+	}
+	return false
 }
 
 func (p *Parser) varDeclaration() Stmt {
@@ -121,15 +256,30 @@ func (p *Parser) varDeclaration() Stmt {
 		initializer = p.expression()
 	}
 	p.consume(scanner.SEMICOLON, "expect ';' after variable declaration")
-	return &Var{ident, initializer}
+	return &Var{name: ident, initializer: initializer}
+}
+
+// constDeclaration is a var that must be initialized and can never be assigned again
+func (p *Parser) constDeclaration() Stmt {
+	ident := p.consume(scanner.IDENTIFIER, "expect constant name")
+	p.consume(scanner.EQUAL, "expect '=' after constant name, constants must be initialized")
+	initializer := p.expression()
+	p.consume(scanner.SEMICOLON, "expect ';' after constant declaration")
+	return &Var{name: ident, initializer: initializer, constant: true}
 }
 
 func (p *Parser) statement() Stmt {
+	if p.check(scanner.IDENTIFIER) && p.checkNext(scanner.COLON) {
+		return p.labeledStatement()
+	}
 	if p.match(scanner.BREAK) {
 		return p.breakStatement()
 	}
+	if p.match(scanner.CONTINUE) {
+		return p.continueStatement()
+	}
 	if p.match(scanner.FOR) {
-		return p.forStatement()
+		return p.forStatement(scanner.Token{})
 	}
 	if p.match(scanner.IF) {
 		return p.ifStatement()
@@ -141,27 +291,78 @@ func (p *Parser) statement() Stmt {
 		return p.returnStatement()
 	}
 	if p.match(scanner.WHILE) {
-		return p.whileStatement()
+		return p.whileStatement(scanner.Token{})
+	}
+	if p.match(scanner.THROW) {
+		return p.throwStatement()
+	}
+	if p.match(scanner.TRY) {
+		return p.tryStatement()
 	}
 
 	if p.match(scanner.LEFT_BRACE) {
-		return &Block{p.block()}
+		return &Block{statements: p.block()}
 	}
 
 	return p.expressionStatement()
 }
 
+// labeledStatement parses a loop preceded by "name:"
+func (p *Parser) labeledStatement() Stmt {
+	label := p.advance()
+	p.advance()
+	if p.match(scanner.WHILE) {
+		return p.whileStatement(label)
+	}
+	if p.match(scanner.FOR) {
+		return p.forStatement(label)
+	}
+	p.error(p.peek(), diagnostics.ExpectedToken, "expect 'while' or 'for' after label")
+	panic(ParseErrorObj)
+}
+
 func (p *Parser) breakStatement() Stmt {
+	keyword := p.previous()
+	label := p.loopLabel()
+	p.consume(scanner.SEMICOLON, "expect ';' after break")
 	// user is trying to break outside a loop
-	if p.loops == 0 {
-		p.error_reporter(p.peek().Line, "", "'break' outside loop")
+	if len(p.loops) == 0 {
+		p.report(diagnostics.Error(diagnostics.BreakOutsideLoop, keyword.Span(), "'break' outside loop").
+			WithNote("'break' can only be used inside 'while' and 'for' loops"))
 		return nil
 	}
-	p.consume(scanner.SEMICOLON, "expect ';' after break")
-	return &Break{}
+	return &Break{keyword: keyword, label: label}
+}
+
+func (p *Parser) continueStatement() Stmt {
+	keyword := p.previous()
+	label := p.loopLabel()
+	p.consume(scanner.SEMICOLON, "expect ';' after continue")
+	if len(p.loops) == 0 {
+		p.report(diagnostics.Error(diagnostics.ContinueOutsideLoop, keyword.Span(), "'continue' outside loop").
+			WithNote("'continue' can only be used inside 'while' and 'for' loops"))
+		return nil
+	}
+	return &Continue{keyword: keyword, label: label}
+}
+
+// loopLabel parses the optional label after break and continue, checking it names an enclosing loop
+func (p *Parser) loopLabel() scanner.Token {
+	if !p.match(scanner.IDENTIFIER) {
+		return scanner.Token{}
+	}
+	label := p.previous()
+	for _, l := range p.loops {
+		if l == label.Lexeme {
+			return label
+		}
+	}
+	p.report(diagnostics.Error(diagnostics.UndefinedLabel, label.Span(), fmt.Sprintf("no enclosing loop labeled '%s'", label.Lexeme)))
+	return label
 }
 
-func (p *Parser) forStatement() Stmt {
+func (p *Parser) forStatement(label scanner.Token) Stmt {
+	keyword := p.previous()
 	p.consume(scanner.LEFT_PAREN, "expect '(' after for")
 	var initializer Stmt
 	if p.match(scanner.SEMICOLON) {
@@ -184,27 +385,24 @@ func (p *Parser) forStatement() Stmt {
 	}
 	p.consume(scanner.RIGHT_PAREN, "expect ')' after for clause")
 
-	p.loops += 1
-	defer func() { p.loops -= 1 }()
+	p.loops = append(p.loops, label.Lexeme)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
 	body := p.statement()
 
-	if increment != nil {
-		body = &Block{[]Stmt{body, increment}}
-	}
-
 	if condition == nil {
-		condition = &Literal{true}
+		condition = &Literal{value: true}
 	}
-	body = &While{condition: condition, body: body}
+	body = &While{keyword: keyword, label: label, condition: condition, body: body, increment: increment}
 
 	if initializer != nil {
-		body = &Block{[]Stmt{initializer, body}}
+		body = &Block{statements: []Stmt{initializer, body}}
 	}
 
 	return body
 }
 
 func (p *Parser) ifStatement() Stmt {
+	keyword := p.previous()
 	p.consume(scanner.LEFT_PAREN, "expect '(' after if")
 	condition := p.expression()
 	p.consume(scanner.RIGHT_PAREN, "expect ')' after if condition")
@@ -215,13 +413,14 @@ func (p *Parser) ifStatement() Stmt {
 		falseBranch = p.statement()
 	}
 
-	return &If{condition: condition, trueBranch: trueBranch, falseBranch: falseBranch}
+	return &If{keyword: keyword, condition: condition, trueBranch: trueBranch, falseBranch: falseBranch}
 }
 
 func (p *Parser) printStatement() Stmt {
+	keyword := p.previous()
 	expr := p.expression()
 	p.consume(scanner.SEMICOLON, "expect ';' after expression")
-	return &Print{expression: expr}
+	return &Print{keyword: keyword, expression: expr}
 }
 
 func (p *Parser) returnStatement() Stmt {
@@ -234,21 +433,54 @@ func (p *Parser) returnStatement() Stmt {
 	return &Return{keyword: keyword, value: expr}
 }
 
-func (p *Parser) whileStatement() Stmt {
+func (p *Parser) whileStatement(label scanner.Token) Stmt {
+	keyword := p.previous()
 	p.consume(scanner.LEFT_PAREN, "expect '(' after while")
 	condition := p.expression()
 	p.consume(scanner.RIGHT_PAREN, "expect ')' after while condition")
 
-	p.loops += 1
-	defer func() { p.loops -= 1 }()
+	p.loops = append(p.loops, label.Lexeme)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
 	body := p.statement()
 
-	return &While{condition: condition, body: body}
+	return &While{keyword: keyword, label: label, condition: condition, body: body}
+}
+
+func (p *Parser) throwStatement() Stmt {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(scanner.SEMICOLON, "expect ';' after thrown value")
+	return &Throw{keyword: keyword, value: value}
+}
+
+func (p *Parser) tryStatement() Stmt {
+	stmt := &Try{keyword: p.previous()}
+	p.consume(scanner.LEFT_BRACE, "expect '{' after try")
+	stmt.body = &Block{statements: p.block()}
+
+	if p.match(scanner.CATCH) {
+		p.consume(scanner.LEFT_PAREN, "expect '(' after catch")
+		stmt.catchName = p.consume(scanner.IDENTIFIER, "expect name of the caught value")
+		p.consume(scanner.RIGHT_PAREN, "expect ')' after caught value name")
+		p.consume(scanner.LEFT_BRACE, "expect '{' before catch body")
+		stmt.catchBody = &Block{statements: p.block()}
+	}
+	if p.match(scanner.FINALLY) {
+		p.consume(scanner.LEFT_BRACE, "expect '{' after finally")
+		stmt.finallyBody = &Block{statements: p.block()}
+	}
+	if stmt.catchBody == nil && stmt.finallyBody == nil {
+		p.error(p.peek(), diagnostics.ExpectedToken, "expect 'catch' or 'finally' after try block")
+		panic(ParseErrorObj)
+	}
+	return stmt
 }
 
 func (p *Parser) block() (stmts []Stmt) {
 	for !p.isAtEnd() && !p.check(scanner.RIGHT_BRACE) {
-		stmts = append(stmts, p.declaration())
+		if stmt := p.declaration(); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
 	}
 	p.consume(scanner.RIGHT_BRACE, "expect '}' at end of block")
 	return
@@ -278,20 +510,25 @@ func (p *Parser) assignment(args ...bool) Expr {
 		equals := p.previous()
 		right := p.assignment(false)
 		if variable, ok := expr.(*Variable); ok {
-			return &Assign{variable.name, right}
+			return &Assign{name: variable.name, value: right}
 		} else if get, ok := expr.(*Get); ok {
 			return &Set{object: get.instance, name: get.name, value: right}
+		} else if index, ok := expr.(*Index); ok {
+			return &IndexSet{object: index.object, bracket: index.bracket, index: index.index, value: right}
 		}
-		p.error_reporter(equals.Line, "", "invalid assignment target")
+		p.report(diagnostics.Error(diagnostics.InvalidAssignment, exprSpan(expr).To(equals.Span()), "invalid assignment target").
+			WithNote("only variables, fields and indexed elements can be assigned to"))
 	}
 
-	// TODO: investigate cleaner way for incrementing/decrementing
-	if p.match(scanner.INCREMENT, scanner.DECREMENT) {
+	if p.match(scanner.PLUS_EQUAL, scanner.MINUS_EQUAL, scanner.STAR_EQUAL, scanner.SLASH_EQUAL, scanner.MODULO_EQUAL) {
 		operator := p.previous()
-		if variable, ok := expr.(*Variable); ok {
-			return &Assign{variable.name, &Unary{operator, &Variable{variable.name}}}
+		right := p.assignment(false)
+		switch expr.(type) {
+		case *Variable, *Get, *Index:
+			return &CompoundAssign{target: expr, operator: operator, value: right}
 		}
-		p.error_reporter(operator.Line, "", "invalid assignment target")
+		p.report(diagnostics.Error(diagnostics.InvalidAssignment, exprSpan(expr).To(operator.Span()), "invalid assignment target").
+			WithNote("only variables, fields and indexed elements can be assigned to"))
 	}
 	return expr
 }
@@ -385,7 +622,15 @@ func (p *Parser) unary() Expr {
 		right := p.unary()
 		return &Unary{operator, right}
 	}
-	return p.call()
+	if p.match(scanner.INCREMENT, scanner.DECREMENT) {
+		operator := p.previous()
+		return &Update{operator: operator, target: p.unary(), prefix: true}
+	}
+	expr := p.call()
+	if p.match(scanner.INCREMENT, scanner.DECREMENT) {
+		return &Update{operator: p.previous(), target: expr}
+	}
+	return expr
 }
 
 func (p *Parser) call() Expr {
@@ -397,6 +642,11 @@ func (p *Parser) call() Expr {
 		} else if p.match(scanner.DOT) {
 			name := p.consume(scanner.IDENTIFIER, "expect property name after '.'")
 			expr = &Get{name: name, instance: expr}
+		} else if p.match(scanner.LEFT_BRACKET) {
+			bracket := p.previous()
+			index := p.expression()
+			end := p.consume(scanner.RIGHT_BRACKET, "expect ']' after index")
+			expr = &Index{object: expr, bracket: bracket, index: index, end: end}
 		} else {
 			break
 		}
@@ -405,91 +655,183 @@ func (p *Parser) call() Expr {
 }
 
 func (p *Parser) finishCall(callee Expr) Expr {
-	var arguments []Expr
+	var arguments []argument
 	// function with no arguments
 	if p.match(scanner.RIGHT_PAREN) {
 		return &Call{callee: callee, arguments: arguments, paren: p.previous()}
 	}
 
-	arguments = append(arguments, p.argument())
+	arguments = append(arguments, p.argument(arguments))
 	for p.match(scanner.COMMA) {
 		if len(arguments) > 255 {
-			p.error_reporter(p.peek().Line, "", "can't have more than 255 arguments")
+			p.report(diagnostics.Error(diagnostics.TooManyArguments, p.peek().Span(), "can't have more than 255 arguments"))
 		}
-		arguments = append(arguments, p.argument())
+		arguments = append(arguments, p.argument(arguments))
 	}
 	p.consume(scanner.RIGHT_PAREN, "expect ')' after arguments")
 	return &Call{callee: callee, arguments: arguments, paren: p.previous()}
 }
 
-func (p *Parser) argument() Stmt {
-	if p.match(scanner.FUN) {
-		return p.function("function", true)
+// argument parses a positional, named or spread argument, named arguments come after all the others
+func (p *Parser) argument(previous []argument) argument {
+	if p.check(scanner.IDENTIFIER) && p.checkNext(scanner.COLON) {
+		name := p.advance()
+		p.advance()
+		return argument{name: name, value: p.expression(true)}
 	}
-	return p.expression(true)
+
+	var arg argument
+	if p.match(scanner.ELLIPSIS) {
+		arg.spread = p.previous()
+	}
+	arg.value = p.expression(true)
+	if len(previous) > 0 && previous[len(previous)-1].isNamed() {
+		span := exprSpan(arg.value)
+		if arg.isSpread() {
+			span = arg.spread.Span().To(span)
+		}
+		p.report(diagnostics.Error(diagnostics.InvalidArgument, span, "positional argument after named arguments").
+			WithSuggestion("pass it before the named arguments"))
+	}
+	return arg
 }
 
 func (p *Parser) primary() Expr {
 	if p.match(scanner.FALSE) {
-		return &Literal{false}
+		return &Literal{value: false, token: p.previous()}
 	}
 	if p.match(scanner.TRUE) {
-		return &Literal{true}
+		return &Literal{value: true, token: p.previous()}
 	}
 	if p.match(scanner.NIL) {
-		return &Literal{nil}
+		return &Literal{value: nil, token: p.previous()}
 	}
 
 	if p.match(scanner.Number, scanner.STRING) {
-		return &Literal{p.previous().Literal}
+		return &Literal{value: p.previous().Literal, token: p.previous()}
+	}
+
+	if p.match(scanner.INTERPOLATION) {
+		return p.interpolation()
 	}
 
 	if p.match(scanner.IDENTIFIER) {
-		return &Variable{p.previous()}
+		return &Variable{name: p.previous()}
 	}
 
 	if p.match(scanner.THIS) {
-		return &This{p.previous()}
+		return &This{keyword: p.previous()}
+	}
+
+	if p.match(scanner.SUPER) {
+		keyword := p.previous()
+		p.consume(scanner.DOT, "expect '.' after 'super'")
+		method := p.consume(scanner.IDENTIFIER, "expect superclass method name")
+		return &Super{keyword: keyword, method: method}
+	}
+
+	if p.match(scanner.FUN) {
+		return p.lambda()
+	}
+
+	if p.check(scanner.LEFT_PAREN) && p.isArrow() {
+		p.advance()
+		return p.arrow()
 	}
 
 	if p.match(scanner.LEFT_PAREN) {
+		paren := p.previous()
 		expr := p.expression()
-		p.consume(scanner.RIGHT_PAREN, "Expect ') after expression.")
-		return &Grouping{expr}
+		end := p.consume(scanner.RIGHT_PAREN, "Expect ') after expression.")
+		return &Grouping{expression: expr, paren: paren, end: end}
+	}
+
+	if p.match(scanner.LEFT_BRACKET) {
+		return p.list()
+	}
+
+	// statements starting with '{' are always parsed as blocks by statement(),
+	// so a brace reaching an expression position can only start a map literal
+	if p.match(scanner.LEFT_BRACE) {
+		return p.mapLiteral()
 	}
 
 	if p.match(scanner.BANG_EQUAL, scanner.EQUAL_EQUAL) {
 		operator := p.previous()
 		p.comparison()
-		p.error(operator, "missing left hand operand")
+		p.error(operator, diagnostics.MissingOperand, "missing left hand operand")
 		return nil
 	}
 
 	if p.match(scanner.GREATER, scanner.GREATER_EQUAL, scanner.LESS, scanner.LESS_EQUAL) {
 		operator := p.previous()
 		p.term()
-		p.error(operator, "missing left hand operand")
+		p.error(operator, diagnostics.MissingOperand, "missing left hand operand")
 		return nil
 	}
 
 	if p.match(scanner.PLUS) {
 		operator := p.previous()
 		p.factor()
-		p.error(operator, "missing left hand operand")
+		p.error(operator, diagnostics.MissingOperand, "missing left hand operand")
 		return nil
 	}
 
 	if p.match(scanner.STAR, scanner.SLASH) {
 		operator := p.previous()
 		p.unary()
-		p.error(operator, "missing left hand operand")
+		p.error(operator, diagnostics.MissingOperand, "missing left hand operand")
 		return nil
 	}
 
-	p.error(p.peek(), "Expect expression.")
+	p.error(p.peek(), diagnostics.ExpectedExpression, "expect expression")
 	panic(ParseErrorObj)
 }
 
+func (p *Parser) interpolation() Expr {
+	start := p.previous()
+	var parts []Expr
+	for {
+		parts = append(parts, &Literal{value: p.previous().Literal, token: p.previous()}, p.expression())
+		if !p.match(scanner.INTERPOLATION) {
+			break
+		}
+	}
+	end := p.consume(scanner.STRING, "expect '}' after interpolated expression")
+	parts = append(parts, &Literal{value: end.Literal, token: end})
+	return &Interpolation{start: start, parts: parts, end: end}
+}
+
+func (p *Parser) list() Expr {
+	bracket := p.previous()
+	var elements []Expr
+	for !p.check(scanner.RIGHT_BRACKET) && !p.isAtEnd() {
+		elements = append(elements, p.expression(true))
+		// trailing comma is allowed
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	end := p.consume(scanner.RIGHT_BRACKET, "expect ']' after list elements")
+	return &List{bracket: bracket, elements: elements, end: end}
+}
+
+func (p *Parser) mapLiteral() Expr {
+	brace := p.previous()
+	var keys, values []Expr
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		keys = append(keys, p.expression(true))
+		p.consume(scanner.COLON, "expect ':' after map key")
+		values = append(values, p.expression(true))
+		// trailing comma is allowed
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	end := p.consume(scanner.RIGHT_BRACE, "expect '}' after map entries")
+	return &Map{brace: brace, keys: keys, values: values, end: end}
+}
+
 func (p *Parser) match(types ...scanner.TokenType) bool {
 	for _, tokenType := range types {
 		if p.check(tokenType) {
@@ -505,7 +847,7 @@ func (p *Parser) consume(tokenType scanner.TokenType, error_msg string) scanner.
 	if p.check(tokenType) {
 		return p.advance()
 	}
-	p.error(p.peek(), error_msg)
+	p.error(p.peek(), diagnostics.ExpectedToken, error_msg)
 	panic(ParseErrorObj)
 }
 
@@ -516,6 +858,14 @@ func (p *Parser) check(tokenType scanner.TokenType) bool {
 	return p.peek().Type == tokenType
 }
 
+// checkNext looks one token past the current one without consuming anything
+func (p *Parser) checkNext(tokenType scanner.TokenType) bool {
+	if p.isAtEnd() || p.tokens[p.current+1].Type == scanner.EOF {
+		return false
+	}
+	return p.tokens[p.current+1].Type == tokenType
+}
+
 func (p *Parser) isAtEnd() bool {
 	return p.peek().Type == scanner.EOF
 }
@@ -535,12 +885,18 @@ func (p *Parser) peek() scanner.Token {
 	return p.tokens[p.current]
 }
 
-func (p *Parser) error(token scanner.Token, message string) {
+func (p *Parser) report(d *diagnostics.Diagnostic) {
+	p.hadError = true
+	p.reporter.Report(d)
+}
+
+func (p *Parser) error(token scanner.Token, code diagnostics.Code, message string) *diagnostics.Diagnostic {
+	d := diagnostics.Error(code, token.Span(), message)
 	if token.Type == scanner.EOF {
-		p.error_reporter(token.Line, " at end", message)
-	} else {
-		p.error_reporter(token.Line, " at '"+token.Lexeme+"'", message)
+		d.WithNote("reached the end of the file")
 	}
+	p.report(d)
+	return d
 }
 
 func (p *Parser) synchronize() {
@@ -558,6 +914,8 @@ func (p *Parser) synchronize() {
 			return
 		case scanner.VAR:
 			return
+		case scanner.CONST:
+			return
 		case scanner.FOR:
 			return
 		case scanner.IF:
@@ -568,6 +926,16 @@ func (p *Parser) synchronize() {
 			return
 		case scanner.RETURN:
 			return
+		case scanner.BREAK:
+			return
+		case scanner.CONTINUE:
+			return
+		case scanner.THROW:
+			return
+		case scanner.TRY:
+			return
+		case scanner.IMPORT:
+			return
 		}
 
 		p.advance()
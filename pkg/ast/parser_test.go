@@ -4,6 +4,7 @@ import (
 	"log"
 	"testing"
 
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
@@ -25,25 +26,44 @@ func TestParser(t *testing.T) {
 		{"var y;", []string{"(var y nil)"}},
 		{"var x = ;", []string{}},
 		{"var x; x = 3;", []string{"(var x nil)", "(x =  3)"}},
+		{"const x = 3 + 5;", []string{"(const x (+ 3 5))"}},
+		{"const x;", []string{}},
 		{"1 == 2 and 3 < 2 or 4 > 3;", []string{"(or (and (== 1 2) (< 3 2)) (> 4 3))"}},
 		//while
-		{"var x = 0; while (x > 5) { print x; x++; }", []string{"(var x 0)", "while ((> x 5)) {{(print x) (x =  (++ x)) }}"}},
+		{"var x = 0; while (x > 5) { print x; x++; }", []string{"(var x 0)", "while ((> x 5)) {{(print x) (post++ x) }}"}},
 		//for
-		{"for (var x = 1; x < 5; x ++) print x;", []string{"{(var x 1) while ((< x 5)) {{(print x) (x =  (++ x)) }} }"}},
-		{"var x; for (x = 1; x < 5; x ++) print x;", []string{"(var x nil)", "{(x =  1) while ((< x 5)) {{(print x) (x =  (++ x)) }} }"}},
-		{"var x = 1; for (; x < 5; x ++) print x;", []string{"(var x 1)", "while ((< x 5)) {{(print x) (x =  (++ x)) }}"}},
-		{"for (var x = 1;; x ++) print x;", []string{"{(var x 1) while (true) {{(print x) (x =  (++ x)) }} }"}},
+		{"for (var x = 1; x < 5; x ++) print x;", []string{"{(var x 1) while ((< x 5); (post++ x)) {(print x)} }"}},
+		{"var x; for (x = 1; x < 5; x ++) print x;", []string{"(var x nil)", "{(x =  1) while ((< x 5); (post++ x)) {(print x)} }"}},
+		{"var x = 1; for (; x < 5; x ++) print x;", []string{"(var x 1)", "while ((< x 5); (post++ x)) {(print x)}"}},
+		{"for (var x = 1;; x ++) print x;", []string{"{(var x 1) while (true; (post++ x)) {(print x)} }"}},
 		{"for (var x = 1;;) print x;", []string{"{(var x 1) while (true) {(print x)} }"}},
 		{"for (;;) print x;", []string{"while (true) {(print x)}"}},
 		{"for (;;) break;", []string{"while (true) {break}"}},
 		{"break;", []string{""}},
+		{"for (;;) continue;", []string{"while (true) {continue}"}},
+		{"outer: while (true) { while (true) break outer; }", []string{"while (true) {{while (true) {break} }}"}},
+		{"++a.b; c[0]--; -x++;", []string{"(++ a.b)", "(post-- c[0])", "(- (post++ x))"}},
+		{"x += 1; a.b -= c *= 2; xs[i] /= 2; x %= 3;", []string{"(+= x 1)", "(-= a.b (*= c 2))", "(/= xs[i] 2)", "(%= x 3)"}},
 		{"call(x, y);", []string{"(call x y)"}},
+		{"super.greet(x);", []string{"(super.greet x)"}},
+		{"[1, 2 + 3][0] = xs[1];", []string{"(list 1 (+ 2 3))[0] = xs[1]"}},
+		{"var m = {\"a\": 1, 2: x};", []string{"(var m (map a 1 2 x))"}},
+		{"\"a ${x + 1} b ${y}\";", []string{"(interpolate a  (+ x 1)  b  y )"}},
+		{"var f = fun (a, b) { return a; };", []string{"(var f fun (a,b,) { return a })"}},
+		{"var f = (a) => a + 1;", []string{"(var f fun (a,) { return (+ a 1) })"}},
+		{"call(() => 1, 2);", []string{"(call fun () { return 1 } 2)"}},
+		{"(a, b);", []string{"(group (, a b))"}},
+		{"fun f(a, b = 2, ...rest) { return rest; }", []string{"fun f(a,b = 2,...rest,) { return rest }"}},
+		{"var f = (a, b = (1)) => a + b;", []string{"(var f fun (a,b = (group 1),) { return (+ a b) })"}},
+		{"f(1, ...xs, b: c ? 2 : 3);", []string{"(f 1 ...xs b: (? c 2 3))"}},
+		{"import \"lib/math\" as math;", []string{"(import \"lib/math\" as math)"}},
+		{"from \"math\" import sqrt, pi;", []string{"(from \"math\" import sqrt, pi)"}},
 	}
 	for _, tt := range tests {
 		t.Run("", func(t *testing.T) {
-			s := scanner.NewScanner(tt.input, func(int, string) {})
+			s := scanner.NewScanner(tt.input, &diagnostics.Collector{})
 			tokens := s.ScanTokens()
-			parser := NewParser(tokens, func(l int, w, m string) { log.Println("[line ", l, "] Error", w, ": ", m) })
+			parser := NewParser(tokens, diagnostics.ReporterFunc(func(d *diagnostics.Diagnostic) { log.Println("[line ", d.Span.Line, "] Error: ", d.Message) }))
 			stmts, _ := parser.Parse()
 			for i, stmt := range stmts {
 				printer := &AstPrinter{}
@@ -56,3 +76,105 @@ func TestParser(t *testing.T) {
 	}
 
 }
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"print a + b * 2;", "print a + b * 2"},
+		{"x = (1 + 2);", "x = (1 + 2)"},
+		{"obj.list[1 + 2] = f(a, b);", "obj.list[1 + 2] = f(a, b)"},
+		{"if (a) print b; else { print c; }", "if (a) print b; else { print c"},
+		{"var m = {\"a\": [1, 2]};", "m = {\"a\": [1, 2]}"},
+		{"fun f(a) { return a; }", "f(a) { return a"},
+		{"while (true) break;", "while (true) break"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			s := scanner.NewScanner(tt.input, &diagnostics.Collector{})
+			parser := NewParser(s.ScanTokens(), diagnostics.ReporterFunc(func(d *diagnostics.Diagnostic) { t.Errorf("[line %d] Error: %s", d.Span.Line, d.Message) }))
+			stmts, _ := parser.Parse()
+			span := stmts[0].Span()
+			if got := tt.input[span.Start:span.End]; got != tt.expected {
+				t.Errorf("Span(%s) covers %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParserRecoveryAtStatements(t *testing.T) {
+	// each declaration misses its ';' and runs into the next statement, which recovery must not skip
+	input := "var a = 1 2\nthrow 1;\nvar b = 1 2\ntry {} finally {}\nvar c = 1 2\nimport \"m\" as m;\n" +
+		"while (true) { var d = 1 2\ncontinue; var e = 1 2\nbreak; }\n"
+	var collector diagnostics.Collector
+	stmts, _ := NewParser(scanner.NewScanner(input, &collector).ScanTokens(), &collector).Parse()
+	if len(stmts) != 4 {
+		t.Errorf("parsed %d statements, want 4", len(stmts))
+	}
+	if got := len(collector.Diagnostics()); got != 5 {
+		t.Errorf("got %d diagnostics, want 5", got)
+	}
+	if loop, ok := stmts[len(stmts)-1].(*While); !ok || len(loop.body.(*Block).statements) != 2 {
+		t.Errorf("the loop body lost its continue or break: %v", stmts[len(stmts)-1])
+	}
+}
+
+func TestInvalidParametersAndArguments(t *testing.T) {
+	input := "fun f(...a, b) {}\nfun g(a = 1, b) {}\nf(a: 1, 2);\nf(a: 1, ...xs);\n"
+	var collector diagnostics.Collector
+	s := scanner.NewScanner(input, &collector)
+	NewParser(s.ScanTokens(), &collector).Parse()
+	expected := []struct {
+		code   diagnostics.Code
+		line   int
+		column int
+	}{
+		{diagnostics.InvalidParameter, 1, 10},
+		{diagnostics.InvalidParameter, 2, 14},
+		{diagnostics.InvalidArgument, 3, 9},
+		{diagnostics.InvalidArgument, 4, 9},
+	}
+	got := collector.Diagnostics()
+	if len(got) != len(expected) {
+		t.Fatalf("got %d diagnostics, want %d", len(got), len(expected))
+	}
+	for i, e := range expected {
+		if got[i].Code != e.code || got[i].Span.Line != e.line || got[i].Span.Column != e.column {
+			t.Errorf("diagnostic %d = %s at %d:%d, want %s at %d:%d", i, got[i].Code, got[i].Span.Line, got[i].Span.Column, e.code, e.line, e.column)
+		}
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	input := "var a = ;\nprint a;\nvar b = 1 +;\nwhile (true) { break; }\nbreak;\nwhile (true) { continue inner; }\n"
+	var collector diagnostics.Collector
+	s := scanner.NewScanner(input, &collector)
+	parser := NewParser(s.ScanTokens(), &collector)
+	stmts, err := parser.Parse()
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	// the statements around the broken ones are still parsed
+	if len(stmts) != 3 {
+		t.Errorf("parsed %d statements, want 3", len(stmts))
+	}
+	expected := []struct {
+		code diagnostics.Code
+		line int
+	}{
+		{diagnostics.ExpectedExpression, 1},
+		{diagnostics.ExpectedExpression, 3},
+		{diagnostics.BreakOutsideLoop, 5},
+		{diagnostics.UndefinedLabel, 6},
+	}
+	got := collector.Diagnostics()
+	if len(got) != len(expected) {
+		t.Fatalf("got %d diagnostics, want %d", len(got), len(expected))
+	}
+	for i, e := range expected {
+		if got[i].Code != e.code || got[i].Span.Line != e.line {
+			t.Errorf("diagnostic %d = %s at line %d, want %s at line %d", i, got[i].Code, got[i].Span.Line, e.code, e.line)
+		}
+	}
+}
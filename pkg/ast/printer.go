@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 )
 
 type AstPrinter struct{}
@@ -22,6 +23,9 @@ func (p *AstPrinter) VisitClassStmt(stmt *Class) any {
 }
 
 func (p *AstPrinter) VisitVarStmt(stmt *Var) any {
+	if stmt.constant {
+		return p.parenthesize(fmt.Sprintf("const %s", stmt.name.Lexeme), stmt.initializer)
+	}
 	return p.parenthesize(fmt.Sprintf("var %s", stmt.name.Lexeme), stmt.initializer)
 }
 
@@ -54,6 +58,9 @@ func (p *AstPrinter) VisitPrintStmt(stmt *Print) any {
 }
 
 func (p *AstPrinter) VisitWhileStmt(stmt *While) any {
+	if stmt.increment != nil {
+		return fmt.Sprintf("while (%s; %s) {%s}", stmt.condition.accept(p), stmt.increment.accept(p), stmt.body.accept(p))
+	}
 	return fmt.Sprintf("while (%s) {%s}", stmt.condition.accept(p), stmt.body.accept(p))
 }
 
@@ -61,10 +68,47 @@ func (p *AstPrinter) VisitBreakStatement(stmt *Break) any {
 	return "break"
 }
 
+func (p *AstPrinter) VisitContinueStmt(stmt *Continue) any {
+	return "continue"
+}
+
+func (p *AstPrinter) VisitImportStmt(stmt *Import) any {
+	if len(stmt.names) == 0 {
+		return fmt.Sprintf("(import %q as %s)", stmt.path.Literal, stmt.alias.Lexeme)
+	}
+	names := make([]string, len(stmt.names))
+	for i, name := range stmt.names {
+		names[i] = name.Lexeme
+	}
+	return fmt.Sprintf("(from %q import %s)", stmt.path.Literal, strings.Join(names, ", "))
+}
+
+func (p *AstPrinter) VisitThrowStmt(stmt *Throw) any {
+	return p.parenthesize("throw", stmt.value)
+}
+
+func (p *AstPrinter) VisitTryStmt(stmt *Try) any {
+	res := fmt.Sprintf("try %v", stmt.body.accept(p))
+	if stmt.catchBody != nil {
+		res += fmt.Sprintf(" catch (%s) %v", stmt.catchName.Lexeme, stmt.catchBody.accept(p))
+	}
+	if stmt.finallyBody != nil {
+		res += fmt.Sprintf(" finally %v", stmt.finallyBody.accept(p))
+	}
+	return res
+}
+
 func (p *AstPrinter) VisitFunctionStmt(stmt *Function) any {
 	res := fmt.Sprintf("fun %s(", stmt.name.Lexeme)
-	for _, p := range stmt.params {
-		res += p.Lexeme + ","
+	for i, param := range stmt.params {
+		if stmt.rest && i == len(stmt.params)-1 {
+			res += "..."
+		}
+		res += param.Lexeme
+		if stmt.defaults[i] != nil {
+			res += fmt.Sprintf(" = %v", stmt.defaults[i].accept(p))
+		}
+		res += ","
 	}
 	res += ") { "
 	for _, s := range stmt.body {
@@ -74,15 +118,41 @@ func (p *AstPrinter) VisitFunctionStmt(stmt *Function) any {
 	return res
 }
 
+func (p *AstPrinter) VisitCompoundAssignExpr(expr *CompoundAssign) any {
+	return p.parenthesize(expr.operator.Lexeme, expr.target, expr.value)
+}
+
+func (p *AstPrinter) VisitUpdateExpr(expr *Update) any {
+	if expr.prefix {
+		return p.parenthesize(expr.operator.Lexeme, expr.target)
+	}
+	return p.parenthesize("post"+expr.operator.Lexeme, expr.target)
+}
+
+func (p *AstPrinter) VisitLambdaExpr(expr *Lambda) any {
+	return p.VisitFunctionStmt(expr.function)
+}
+
 func (p *AstPrinter) VisitReturnStmt(stmt *Return) any {
 	return fmt.Sprintf("return %v", stmt.value.accept(p))
 }
 
 func (p *AstPrinter) VisitCallExpr(expr *Call) any {
-	if callee, ok := expr.callee.accept(p).(string); ok {
-		return p.parenthesize(callee, expr.arguments...)
+	callee, ok := expr.callee.accept(p).(string)
+	if !ok {
+		return ""
 	}
-	return ""
+	res := "(" + callee
+	for _, arg := range expr.arguments {
+		res += " "
+		if arg.isNamed() {
+			res += arg.name.Lexeme + ": "
+		} else if arg.isSpread() {
+			res += "..."
+		}
+		res += fmt.Sprintf("%v", arg.value.accept(p))
+	}
+	return res + ")"
 }
 
 func (p *AstPrinter) VisitGetExpr(expr *Get) any {
@@ -100,6 +170,37 @@ func (p *AstPrinter) VisitThisExpr(expr *This) any {
 	return "this"
 }
 
+func (p *AstPrinter) VisitSuperExpr(expr *Super) any {
+	return fmt.Sprintf("super.%s", expr.method.Lexeme)
+}
+
+func (p *AstPrinter) VisitInterpolationExpr(expr *Interpolation) any {
+	return p.parenthesize("interpolate", expr.parts...)
+}
+
+func (p *AstPrinter) VisitListExpr(expr *List) any {
+	return p.parenthesize("list", expr.elements...)
+}
+
+func (p *AstPrinter) VisitMapExpr(expr *Map) any {
+	entries := make([]Expr, 0, 2*len(expr.keys))
+	for i := range expr.keys {
+		entries = append(entries, expr.keys[i], expr.values[i])
+	}
+	return p.parenthesize("map", entries...)
+}
+
+func (p *AstPrinter) VisitIndexExpr(expr *Index) any {
+	return fmt.Sprintf("%s[%s]", expr.object.accept(p), expr.index.accept(p))
+}
+
+func (p *AstPrinter) VisitIndexSetExpr(expr *IndexSet) any {
+	return fmt.Sprintf(
+		"%s[%s] = %s",
+		expr.object.accept(p), expr.index.accept(p), expr.value.accept(p),
+	)
+}
+
 func (p *AstPrinter) VisitAssignExpr(expr *Assign) any {
 	return p.parenthesize(expr.name.Lexeme+" = ", expr.value)
 }
@@ -14,10 +14,10 @@ func TestPrinter(t *testing.T) {
 		{&Binary{
 			&Unary{
 				scanner.Token{Type: scanner.MINUS, Lexeme: "-", Literal: nil, Line: 1},
-				&Literal{123}},
+				&Literal{value: 123}},
 			scanner.Token{Type: scanner.STAR, Lexeme: "*", Literal: nil, Line: 1},
 			&Grouping{
-				&Literal{45.67}}}, "(* (- 123) (group 45.67))"},
+				expression: &Literal{value: 45.67}}}, "(* (- 123) (group 45.67))"},
 	}
 	for _, tt := range tests {
 		t.Run("", func(t *testing.T) {
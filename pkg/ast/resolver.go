@@ -1,52 +1,109 @@
 package ast
 
 import (
+	"fmt"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 	"github.com/fadyZohdy/gLox/pkg/lib/stack"
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
+// Resolver binds every local variable to the scope and slot it lives in, recording it in the tree
+// so the interpreter can index frames directly, and reports the errors it finds along the way.
 type Resolver struct {
-	interpreter      *Interpreter
-	scopes           *stack.Stack[map[string]bool]
-	error_reporter   func(int, string, string)
+	scopes           *stack.Stack[*scope]
+	reporter         diagnostics.Reporter
 	visitedFunctions *stack.Stack[FunctionType]
-	inClass          bool
+	currentClass     ClassType
+}
+
+type scope struct {
+	// slots gives every variable declared in the scope its index in the frame
+	slots map[string]int
+	// defined is false while the variable's initializer is being resolved
+	defined map[string]bool
+	// constants holds the declaration of every const in the scope
+	constants map[string]scanner.Token
+	size      int
 }
 
-func NewResolver(interpreter *Interpreter, error_reporter func(int, string, string)) *Resolver {
+func NewResolver(reporter diagnostics.Reporter) *Resolver {
 	return &Resolver{
-		interpreter, stack.New[map[string]bool](), error_reporter, stack.New[FunctionType](), false,
+		stack.New[*scope](), reporter, stack.New[FunctionType](), NO_CLASS,
 	}
 }
 
 func (r *Resolver) VisitClassStmt(class *Class) any {
-	r.inClass = true
-	r.declare(class.name)
+	class.binding = r.declare(class.name)
 	r.define(class.name)
 
+	// static fields are evaluated outside of any instance so they can't see "this"
+	for _, field := range class.staticFields {
+		r.resolveExpr(field.initializer)
+	}
+
+	enclosingClass := r.currentClass
+	r.currentClass = CLASS
+
+	if class.superclass != nil {
+		if class.superclass.name.Lexeme == class.name.Lexeme {
+			r.reporter.Report(diagnostics.Error(diagnostics.SelfInheritance, class.superclass.name.Span(), "a class can't inherit from itself"))
+		}
+		r.currentClass = SUBCLASS
+		r.resolveExpr(class.superclass)
+
+		// the interpreter keeps the superclass alone in the frame around the methods
+		r.beginScope()
+		r.declare(scanner.Token{Lexeme: "super"})
+		r.define(scanner.Token{Lexeme: "super"})
+	}
+
+	// and binds "this" alone in the frame right inside that
 	r.beginScope()
-	scope := r.scopes.Peek()
-	(*scope)["this"] = true
+	r.declare(scanner.Token{Lexeme: "this"})
+	r.define(scanner.Token{Lexeme: "this"})
 
 	for _, method := range class.methods {
-		if method.name.Lexeme == "init" {
+		if method.functionType == STATIC_METHOD {
+			r.resolveFunction(method, STATIC_METHOD)
+		} else if method.name.Lexeme == "init" {
 			r.resolveFunction(method, CONSTRUCTOR)
 		} else {
 			r.resolveFunction(method, METHOD)
 		}
 	}
 	r.endScope()
-	r.inClass = false
+
+	if class.superclass != nil {
+		r.endScope()
+	}
+
+	r.currentClass = enclosingClass
 	return nil
 }
 
 func (r *Resolver) VisitBlockStmt(block *Block) any {
+	if !declaresVariables(block.statements) {
+		r.Resolve(&block.statements)
+		return nil
+	}
 	r.beginScope()
 	r.Resolve(&block.statements)
-	r.endScope()
+	block.slots = r.endScope()
 	return nil
 }
 
+// declaresVariables reports whether stmts need a scope of their own
+func declaresVariables(stmts []Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case *Var, *Function, *Class, *Import:
+			return true
+		}
+	}
+	return false
+}
+
 /*
 *
 We split binding into two steps, declaring then defining, in order to handle funny edge cases like this:
@@ -59,7 +116,10 @@ var a = "outer";
 *
 */
 func (r *Resolver) VisitVarStmt(varStmt *Var) any {
-	r.declare(varStmt.name)
+	varStmt.binding = r.declare(varStmt.name)
+	if varStmt.constant && !r.scopes.IsEmpty() {
+		(*r.scopes.Peek()).constants[varStmt.name.Lexeme] = varStmt.name
+	}
 	if varStmt.initializer != nil {
 		r.resolveExpr(varStmt.initializer)
 	}
@@ -69,29 +129,53 @@ func (r *Resolver) VisitVarStmt(varStmt *Var) any {
 
 func (r *Resolver) VisitVariableExpr(expr *Variable) any {
 	if !r.scopes.IsEmpty() {
-		scope := r.scopes.Peek()
-		if defined, ok := (*scope)[expr.name.Lexeme]; ok && !defined {
-			r.error_reporter(expr.name.Line, expr.name.Lexeme, "can't read local variable in its own initializer")
+		scope := *r.scopes.Peek()
+		if defined, ok := scope.defined[expr.name.Lexeme]; ok && !defined {
+			r.reporter.Report(diagnostics.Error(diagnostics.SelfReference, expr.name.Span(), "can't read local variable in its own initializer").
+				WithSuggestion(fmt.Sprintf("rename the new '%s' if you meant the one from an outer scope", expr.name.Lexeme)))
 		}
 	}
-	r.resolveLocal(expr, expr.name)
+	expr.binding = r.resolveLocal(expr.name)
 	return nil
 }
 
 func (r *Resolver) VisitAssignExpr(expr *Assign) any {
 	r.resolveExpr(expr.value)
-	r.resolveLocal(expr, expr.name)
+	expr.binding = r.resolveLocal(expr.name)
+	r.checkAssignable(expr.name)
 	return nil
 }
 
 func (r *Resolver) VisitFunctionStmt(stmt *Function) any {
-	r.declare(stmt.name)
+	stmt.binding = r.declare(stmt.name)
 	r.define(stmt.name)
 
 	r.resolveFunction(stmt, FUNCTION)
 	return nil
 }
 
+func (r *Resolver) VisitCompoundAssignExpr(expr *CompoundAssign) any {
+	r.resolveExpr(expr.target)
+	r.resolveExpr(expr.value)
+	if variable, ok := expr.target.(*Variable); ok {
+		r.checkAssignable(variable.name)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitUpdateExpr(expr *Update) any {
+	r.resolveExpr(expr.target)
+	if variable, ok := expr.target.(*Variable); ok {
+		r.checkAssignable(variable.name)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitLambdaExpr(expr *Lambda) any {
+	r.resolveFunction(expr.function, FUNCTION)
+	return nil
+}
+
 func (r *Resolver) VisitExpressionStmt(stmt *Expression) any {
 	r.resolveExpr(stmt.expression)
 
@@ -116,6 +200,9 @@ func (r *Resolver) VisitPrintStmt(stmt *Print) any {
 func (r *Resolver) VisitWhileStmt(stmt *While) any {
 	r.resolveStmt(stmt.condition)
 	r.resolveStmt(stmt.body)
+	if stmt.increment != nil {
+		r.resolveExpr(stmt.increment)
+	}
 
 	return nil
 }
@@ -124,13 +211,18 @@ func (r *Resolver) VisitBreakStatement(stmt *Break) any {
 	return nil
 }
 
+func (r *Resolver) VisitContinueStmt(stmt *Continue) any {
+	return nil
+}
+
 func (r *Resolver) VisitReturnStmt(stmt *Return) any {
 	if r.visitedFunctions.Len() == 0 {
-		r.error_reporter(stmt.keyword.Line, "", "return outside function body")
+		r.reporter.Report(diagnostics.Error(diagnostics.ReturnOutsideFunc, stmt.keyword.Span(), "return outside function body"))
 	}
 	if stmt.value != nil {
-		if *r.visitedFunctions.Peek() == CONSTRUCTOR {
-			r.error_reporter(stmt.keyword.Line, "", "can't return a value from an initializer")
+		if r.visitedFunctions.Len() > 0 && *r.visitedFunctions.Peek() == CONSTRUCTOR {
+			r.reporter.Report(diagnostics.Error(diagnostics.ReturnFromInit, stmt.value.Span(), "can't return a value from an initializer").
+				WithNote("initializers always return the new instance"))
 		}
 		r.resolveExpr(stmt.value)
 	}
@@ -138,6 +230,41 @@ func (r *Resolver) VisitReturnStmt(stmt *Return) any {
 	return nil
 }
 
+func (r *Resolver) VisitThrowStmt(stmt *Throw) any {
+	r.resolveExpr(stmt.value)
+	return nil
+}
+
+func (r *Resolver) VisitTryStmt(stmt *Try) any {
+	r.resolveStmt(stmt.body)
+	if stmt.catchBody != nil {
+		// the caught value gets a scope of its own around the catch body
+		r.beginScope()
+		r.declare(stmt.catchName)
+		r.define(stmt.catchName)
+		r.resolveStmt(stmt.catchBody)
+		r.endScope()
+	}
+	if stmt.finallyBody != nil {
+		r.resolveStmt(stmt.finallyBody)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitImportStmt(stmt *Import) any {
+	if len(stmt.names) == 0 {
+		stmt.binding = r.declare(stmt.alias)
+		r.define(stmt.alias)
+		return nil
+	}
+	stmt.bindings = make([]binding, len(stmt.names))
+	for i, name := range stmt.names {
+		stmt.bindings[i] = r.declare(name)
+		r.define(name)
+	}
+	return nil
+}
+
 func (r *Resolver) VisitBinaryExpr(expr *Binary) any {
 	r.resolveExpr(expr.left)
 	r.resolveExpr(expr.right)
@@ -180,7 +307,7 @@ func (r *Resolver) VisitCallExpr(expr *Call) any {
 	r.resolveExpr(expr.callee)
 
 	for _, arg := range expr.arguments {
-		r.resolveExpr(arg)
+		r.resolveExpr(arg.value)
 	}
 
 	return nil
@@ -197,11 +324,61 @@ func (r *Resolver) VisitSetExpr(expr *Set) any {
 	return nil
 }
 
+func (r *Resolver) VisitInterpolationExpr(expr *Interpolation) any {
+	for _, part := range expr.parts {
+		r.resolveExpr(part)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(expr *List) any {
+	for _, element := range expr.elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(expr *Map) any {
+	for i := range expr.keys {
+		r.resolveExpr(expr.keys[i])
+		r.resolveExpr(expr.values[i])
+	}
+	return nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *Index) any {
+	r.resolveExpr(expr.object)
+	r.resolveExpr(expr.index)
+	return nil
+}
+
+func (r *Resolver) VisitIndexSetExpr(expr *IndexSet) any {
+	r.resolveExpr(expr.object)
+	r.resolveExpr(expr.index)
+	r.resolveExpr(expr.value)
+	return nil
+}
+
 func (r *Resolver) VisitThisExpr(expr *This) any {
-	if !r.inClass {
-		r.error_reporter(expr.keyword.Line, "", "can't use 'this' outside of a class")
+	if r.currentClass == NO_CLASS {
+		r.reporter.Report(diagnostics.Error(diagnostics.ThisOutsideClass, expr.keyword.Span(), "can't use 'this' outside of a class"))
+	} else if r.inStaticMethod() {
+		r.reporter.Report(diagnostics.Error(diagnostics.ThisInStaticMethod, expr.keyword.Span(), "can't use 'this' inside a static method").
+			WithNote("static methods are called on the class, not on an instance"))
+	}
+	expr.binding = r.resolveLocal(expr.keyword)
+	return nil
+}
+
+func (r *Resolver) VisitSuperExpr(expr *Super) any {
+	if r.currentClass == NO_CLASS {
+		r.reporter.Report(diagnostics.Error(diagnostics.SuperOutsideClass, expr.keyword.Span(), "can't use 'super' outside of a class"))
+	} else if r.currentClass != SUBCLASS {
+		r.reporter.Report(diagnostics.Error(diagnostics.SuperWithoutSubclass, expr.keyword.Span(), "can't use 'super' in a class with no superclass"))
+	} else if r.inStaticMethod() {
+		r.reporter.Report(diagnostics.Error(diagnostics.SuperInStaticMethod, expr.keyword.Span(), "can't use 'super' inside a static method"))
 	}
-	r.resolveLocal(expr, expr.keyword)
+	expr.binding = r.resolveLocal(expr.keyword)
 	return nil
 }
 
@@ -219,14 +396,31 @@ func (r *Resolver) resolveExpr(expr Expr) {
 	expr.accept(r)
 }
 
-func (r *Resolver) resolveLocal(expr Expr, name scanner.Token) {
+// resolveLocal finds the closest scope declaring name, names not found anywhere are globals
+func (r *Resolver) resolveLocal(name scanner.Token) binding {
 	for i := r.scopes.Len() - 1; i >= 0; i-- {
 		scope := (*r.scopes)[i]
-		if _, ok := scope[name.Lexeme]; ok {
-			r.interpreter.resolveLocal(expr, r.scopes.Len()-1-i)
-			return
+		if slot, ok := scope.slots[name.Lexeme]; ok {
+			return binding{resolved: true, depth: r.scopes.Len() - 1 - i, slot: slot}
 		}
 	}
+	return binding{}
+}
+
+// checkAssignable reports assignments to local constants, constant globals are only known at runtime
+func (r *Resolver) checkAssignable(name scanner.Token) {
+	for i := r.scopes.Len() - 1; i >= 0; i-- {
+		scope := (*r.scopes)[i]
+		if _, ok := scope.slots[name.Lexeme]; !ok {
+			continue
+		}
+		if declaration, ok := scope.constants[name.Lexeme]; ok {
+			r.reporter.Report(diagnostics.Error(diagnostics.ConstantAssignment, name.Span(), fmt.Sprintf("can't assign to constant '%s'", name.Lexeme)).
+				WithNote(fmt.Sprintf("'%s' was declared as a constant on line %d", name.Lexeme, declaration.Line)).
+				WithSuggestion(fmt.Sprintf("declare '%s' with 'var' if it needs to change", name.Lexeme)))
+		}
+		return
+	}
 }
 
 func (r *Resolver) resolveFunction(stmt *Function, functionType FunctionType) {
@@ -234,33 +428,62 @@ func (r *Resolver) resolveFunction(stmt *Function, functionType FunctionType) {
 
 	r.beginScope()
 
-	for _, param := range stmt.params {
+	// defaults are resolved in the function's scope, they can use the parameters before them
+	for i, param := range stmt.params {
 		r.declare(param)
+		if stmt.defaults[i] != nil {
+			r.resolveExpr(stmt.defaults[i])
+		}
 		r.define(param)
 	}
 	r.Resolve(&stmt.body)
 
-	r.endScope()
+	stmt.slots = r.endScope()
 
 	r.visitedFunctions.Pop()
 }
 
+// inStaticMethod reports whether the closest enclosing method is a static one.
+// plain functions nested inside a method are skipped over since they capture its "this".
+func (r *Resolver) inStaticMethod() bool {
+	for i := r.visitedFunctions.Len() - 1; i >= 0; i-- {
+		switch (*r.visitedFunctions)[i] {
+		case STATIC_METHOD:
+			return true
+		case METHOD, CONSTRUCTOR:
+			return false
+		}
+	}
+	return false
+}
+
 func (r *Resolver) beginScope() {
-	r.scopes.Push(make(map[string]bool))
+	r.scopes.Push(&scope{slots: make(map[string]int), defined: make(map[string]bool), constants: make(map[string]scanner.Token)})
 }
 
-func (r *Resolver) endScope() {
-	r.scopes.Pop()
+// endScope returns how many slots the scope's frame needs
+func (r *Resolver) endScope() int {
+	return (*r.scopes.Pop()).size
 }
 
-func (r *Resolver) declare(name scanner.Token) {
+// declare gives name the next free slot of the current scope. redeclaring a name takes a new slot
+// so closures holding on to the previous variable keep seeing it.
+// declarations outside of any scope are globals.
+func (r *Resolver) declare(name scanner.Token) binding {
 	if r.scopes.IsEmpty() {
-		return
+		return binding{}
 	}
 
-	scope := r.scopes.Peek()
-
-	(*scope)[name.Lexeme] = false
+	scope := *r.scopes.Peek()
+	if declaration, ok := scope.constants[name.Lexeme]; ok {
+		r.reporter.Report(diagnostics.Error(diagnostics.ConstantRedeclared, name.Span(), fmt.Sprintf("can't redeclare constant '%s'", name.Lexeme)).
+			WithNote(fmt.Sprintf("'%s' was declared as a constant on line %d", name.Lexeme, declaration.Line)))
+	}
+	slot := scope.size
+	scope.size++
+	scope.slots[name.Lexeme] = slot
+	scope.defined[name.Lexeme] = false
+	return binding{resolved: true, slot: slot}
 }
 
 func (r *Resolver) define(name scanner.Token) {
@@ -268,7 +491,7 @@ func (r *Resolver) define(name scanner.Token) {
 		return
 	}
 
-	scope := r.scopes.Peek()
+	scope := *r.scopes.Peek()
 
-	(*scope)[name.Lexeme] = true
+	scope.defined[name.Lexeme] = true
 }
@@ -0,0 +1,27 @@
+package ast
+
+import "github.com/fadyZohdy/gLox/pkg/diagnostics"
+
+// the parser leaves nil nodes behind on errors, these helpers make computing spans safe anyway
+
+func exprSpan(expr Expr) diagnostics.Span {
+	if expr == nil {
+		return diagnostics.Span{}
+	}
+	return expr.Span()
+}
+
+func stmtSpan(stmt Stmt) diagnostics.Span {
+	if stmt == nil {
+		return diagnostics.Span{}
+	}
+	return stmt.Span()
+}
+
+func blockSpan(stmts []Stmt) diagnostics.Span {
+	var span diagnostics.Span
+	for _, stmt := range stmts {
+		span = span.To(stmtSpan(stmt))
+	}
+	return span
+}
@@ -0,0 +1,152 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// stdlib lists the native modules every interpreter starts with
+func stdlib(i *Interpreter) []*NativeModule {
+	return []*NativeModule{mathModule(), stringModule(), ioModule(i.stdout), timeModule()}
+}
+
+func clock(arguments []any) (any, error) {
+	return float64(time.Now().UnixNano() / int64(time.Millisecond)), nil
+}
+
+func mathModule() *NativeModule {
+	unary := func(f func(float64) float64) NativeFn {
+		return func(arguments []any) (any, error) {
+			x, err := numberArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			return f(x), nil
+		}
+	}
+	// extreme returns the argument for which better(candidate, current) always holds
+	extreme := func(better func(a, b float64) bool) NativeFn {
+		return func(arguments []any) (any, error) {
+			result, err := numberArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			for i := range arguments[1:] {
+				x, err := numberArg(arguments, i+1)
+				if err != nil {
+					return nil, err
+				}
+				if better(x, result) {
+					result = x
+				}
+			}
+			return result, nil
+		}
+	}
+
+	return NewNativeModule("math").
+		DefineValue("pi", math.Pi).
+		DefineValue("e", math.E).
+		Define("abs", 1, unary(math.Abs)).
+		Define("ceil", 1, unary(math.Ceil)).
+		Define("floor", 1, unary(math.Floor)).
+		Define("round", 1, unary(math.Round)).
+		Define("sqrt", 1, unary(math.Sqrt)).
+		Define("sin", 1, unary(math.Sin)).
+		Define("cos", 1, unary(math.Cos)).
+		Define("log", 1, unary(math.Log)).
+		Define("pow", 2, func(arguments []any) (any, error) {
+			x, err := numberArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			y, err := numberArg(arguments, 1)
+			if err != nil {
+				return nil, err
+			}
+			return math.Pow(x, y), nil
+		}).
+		DefineVariadic("min", 1, Variadic, extreme(func(a, b float64) bool { return a < b })).
+		DefineVariadic("max", 1, Variadic, extreme(func(a, b float64) bool { return a > b }))
+}
+
+func stringModule() *NativeModule {
+	return NewNativeModule("string").
+		Define("len", 1, func(arguments []any) (any, error) {
+			s, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			return float64(utf8.RuneCountInString(s)), nil
+		}).
+		Define("upper", 1, func(arguments []any) (any, error) {
+			s, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			return strings.ToUpper(s), nil
+		}).
+		Define("lower", 1, func(arguments []any) (any, error) {
+			s, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			return strings.ToLower(s), nil
+		}).
+		Define("str", 1, func(arguments []any) (any, error) {
+			return fmt.Sprint(arguments[0]), nil
+		}).
+		Define("number", 1, func(arguments []any) (any, error) {
+			s, err := stringArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("can't convert '%s' to a number", s)
+			}
+			return f, nil
+		})
+}
+
+func ioModule(stdout io.Writer) *NativeModule {
+	return NewNativeModule("io").
+		Define("write", Variadic, func(arguments []any) (any, error) {
+			fmt.Fprint(stdout, joinArgs(arguments))
+			return nil, nil
+		}).
+		Define("writeln", Variadic, func(arguments []any) (any, error) {
+			fmt.Fprintln(stdout, joinArgs(arguments))
+			return nil, nil
+		})
+}
+
+func timeModule() *NativeModule {
+	return NewNativeModule("time").
+		Define("clock", 0, clock).
+		Define("now", 0, func(arguments []any) (any, error) {
+			return float64(time.Now().UnixNano()) / float64(time.Second), nil
+		}).
+		Define("sleep", 1, func(arguments []any) (any, error) {
+			ms, err := numberArg(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+			time.Sleep(time.Duration(ms * float64(time.Millisecond)))
+			return nil, nil
+		})
+}
+
+// joinArgs formats arguments the same way print does, separated by spaces
+func joinArgs(arguments []any) string {
+	parts := make([]string, len(arguments))
+	for i, argument := range arguments {
+		parts[i] = fmt.Sprint(argument)
+	}
+	return strings.Join(parts, " ")
+}
@@ -3,11 +3,14 @@ package ast
 import (
 	"fmt"
 
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 	"github.com/fadyZohdy/gLox/pkg/scanner"
 )
 
 type Stmt interface {
 	accept(visitor Visitor) any
+	// Span locates the statement in the source, it is zero for synthesized nodes
+	Span() diagnostics.Span
 }
 
 type Expression struct {
@@ -18,7 +21,12 @@ func (stmt *Expression) accept(visitor Visitor) any {
 	return visitor.VisitExpressionStmt(stmt)
 }
 
+func (stmt *Expression) Span() diagnostics.Span {
+	return exprSpan(stmt.expression)
+}
+
 type If struct {
+	keyword     scanner.Token
 	condition   Expr
 	trueBranch  Stmt
 	falseBranch Stmt
@@ -32,7 +40,12 @@ func (stmt *If) accept(v Visitor) any {
 	return v.VisitIfStmt(stmt)
 }
 
+func (stmt *If) Span() diagnostics.Span {
+	return stmt.keyword.Span().To(stmtSpan(stmt.trueBranch)).To(stmtSpan(stmt.falseBranch))
+}
+
 type Print struct {
+	keyword    scanner.Token
 	expression Expr
 }
 
@@ -44,12 +57,21 @@ func (stmt *Print) accept(visitor Visitor) any {
 	return visitor.VisitPrintStmt(stmt)
 }
 
+func (stmt *Print) Span() diagnostics.Span {
+	return stmt.keyword.Span().To(exprSpan(stmt.expression))
+}
+
 type Var struct {
 	name        scanner.Token
 	initializer Expr
+	constant    bool
+	binding
 }
 
 func (v Var) String() string {
+	if v.constant {
+		return fmt.Sprintf("const %v = %v", v.name.Lexeme, v.initializer)
+	}
 	return fmt.Sprintf("var %v = %v", v.name.Lexeme, v.initializer)
 }
 
@@ -57,44 +79,138 @@ func (stmt *Var) accept(visitor Visitor) any {
 	return visitor.VisitVarStmt(stmt)
 }
 
+func (stmt *Var) Span() diagnostics.Span {
+	return stmt.name.Span().To(exprSpan(stmt.initializer))
+}
+
 type Block struct {
 	statements []Stmt
+	// slots is how many locals the block declares, blocks without any don't get their own frame
+	slots int
 }
 
 func (stmt *Block) accept(visitor Visitor) any {
 	return visitor.VisitBlockStmt(stmt)
 }
 
+func (stmt *Block) Span() diagnostics.Span {
+	return blockSpan(stmt.statements)
+}
+
 type While struct {
+	keyword scanner.Token
+	// label names the loop for labeled break and continue, its Lexeme is empty for unlabeled loops
+	label     scanner.Token
 	condition Expr
 	body      Stmt
+	// increment is the increment clause of a desugared for loop, it runs after the body and on continue
+	increment Expr
 }
 
 func (stmt *While) accept(v Visitor) any {
 	return v.VisitWhileStmt(stmt)
 }
 
-type Break struct{}
+func (stmt *While) Span() diagnostics.Span {
+	return stmt.keyword.Span().To(stmtSpan(stmt.body))
+}
+
+type Break struct {
+	keyword scanner.Token
+	// label is the loop to break out of, the innermost one when its Lexeme is empty
+	label scanner.Token
+}
 
 func (stmt *Break) accept(v Visitor) any {
 	return v.VisitBreakStatement(stmt)
 }
 
+func (stmt *Break) Span() diagnostics.Span {
+	return stmt.keyword.Span()
+}
+
+type Continue struct {
+	keyword scanner.Token
+	// label is the loop to continue, the innermost one when its Lexeme is empty
+	label scanner.Token
+}
+
+func (stmt *Continue) accept(v Visitor) any {
+	return v.VisitContinueStmt(stmt)
+}
+
+func (stmt *Continue) Span() diagnostics.Span {
+	return stmt.keyword.Span()
+}
+
 type Function struct {
-	name         scanner.Token
-	params       []scanner.Token
+	name   scanner.Token
+	params []scanner.Token
+	// defaults holds the default value of every parameter, nil for the required ones
+	defaults []Expr
+	// rest is set when the last parameter collects the remaining positional arguments into a list
+	rest         bool
 	body         []Stmt
 	functionType FunctionType
+	// class is the name of the class declaring the method, it's empty for plain functions
+	class string
+	binding
+	// slots is the size of the frame holding the parameters and the locals declared directly in the body
+	slots int
+}
+
+func (stmt *Function) accept(v Visitor) any {
+	return v.VisitFunctionStmt(stmt)
 }
 
-func (stmt Function) accept(v Visitor) any {
-	return v.VisitFunctionStmt(&stmt)
+func (stmt *Function) Span() diagnostics.Span {
+	span := stmt.name.Span()
+	if len(stmt.params) > 0 {
+		span = span.To(stmt.params[0].Span())
+	}
+	return span.To(blockSpan(stmt.body))
 }
 
 func (stmt *Function) isAnon() bool {
 	return stmt.name.Lexeme == ""
 }
 
+// arity counts the parameters before the first one with a default, there's no max with a rest parameter
+func (stmt *Function) arity() (min, max int) {
+	fixed := len(stmt.params)
+	max = fixed
+	if stmt.rest {
+		fixed--
+		max = Variadic
+	}
+	for min < fixed && stmt.defaults[min] == nil {
+		min++
+	}
+	return min, max
+}
+
+// plain is set when the function has neither defaults nor a rest parameter, a call to it
+// with one positional argument per parameter doesn't need binding
+func (stmt *Function) plain() bool {
+	if stmt.rest {
+		return false
+	}
+	for _, value := range stmt.defaults {
+		if value != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (stmt *Function) parameters() []string {
+	names := make([]string, len(stmt.params))
+	for i, param := range stmt.params {
+		names[i] = param.Lexeme
+	}
+	return names
+}
+
 type Return struct {
 	keyword scanner.Token
 	value   Expr
@@ -104,15 +220,91 @@ func (stmt *Return) accept(v Visitor) any {
 	return v.VisitReturnStmt(stmt)
 }
 
+func (stmt *Return) Span() diagnostics.Span {
+	return stmt.keyword.Span().To(exprSpan(stmt.value))
+}
+
 type Class struct {
-	name    scanner.Token
-	methods []*Function
+	name         scanner.Token
+	superclass   *Variable
+	methods      []*Function
+	staticFields []*Var
+	binding
 }
 
 func (stmt *Class) accept(v Visitor) any {
 	return v.VisitClassStmt(stmt)
 }
 
+func (stmt *Class) Span() diagnostics.Span {
+	span := stmt.name.Span()
+	for _, method := range stmt.methods {
+		span = span.To(method.Span())
+	}
+	return span
+}
+
 func (c Class) String() string {
 	return fmt.Sprintf("<class %s>", c.name.Lexeme)
 }
+
+type Throw struct {
+	keyword scanner.Token
+	value   Expr
+}
+
+func (stmt *Throw) accept(v Visitor) any {
+	return v.VisitThrowStmt(stmt)
+}
+
+func (stmt *Throw) Span() diagnostics.Span {
+	return stmt.keyword.Span().To(exprSpan(stmt.value))
+}
+
+// Try has a catch clause, a finally clause or both, the missing ones are nil.
+// the caught value is bound alone in a frame around catchBody.
+type Try struct {
+	keyword     scanner.Token
+	body        *Block
+	catchName   scanner.Token
+	catchBody   *Block
+	finallyBody *Block
+}
+
+func (stmt *Try) accept(v Visitor) any {
+	return v.VisitTryStmt(stmt)
+}
+
+func (stmt *Try) Span() diagnostics.Span {
+	span := stmt.keyword.Span().To(stmt.body.Span())
+	if stmt.catchBody != nil {
+		span = span.To(stmt.catchBody.Span())
+	}
+	if stmt.finallyBody != nil {
+		span = span.To(stmt.finallyBody.Span())
+	}
+	return span
+}
+
+// Import binds the module at path to alias, or when names are given,
+// binds each of those members of the module to a variable of the same name
+type Import struct {
+	keyword scanner.Token
+	path    scanner.Token
+	alias   scanner.Token
+	binding
+	names    []scanner.Token
+	bindings []binding
+}
+
+func (stmt *Import) accept(v Visitor) any {
+	return v.VisitImportStmt(stmt)
+}
+
+func (stmt *Import) Span() diagnostics.Span {
+	span := stmt.keyword.Span().To(stmt.path.Span())
+	if len(stmt.names) > 0 {
+		return span.To(stmt.names[len(stmt.names)-1].Span())
+	}
+	return span.To(stmt.alias.Span())
+}
@@ -13,6 +13,15 @@ type Visitor interface {
 	VisitGetExpr(expr *Get) any
 	VisitSetExpr(expr *Set) any
 	VisitThisExpr(expr *This) any
+	VisitSuperExpr(expr *Super) any
+	VisitListExpr(expr *List) any
+	VisitLambdaExpr(expr *Lambda) any
+	VisitUpdateExpr(expr *Update) any
+	VisitCompoundAssignExpr(expr *CompoundAssign) any
+	VisitInterpolationExpr(expr *Interpolation) any
+	VisitMapExpr(expr *Map) any
+	VisitIndexExpr(expr *Index) any
+	VisitIndexSetExpr(expr *IndexSet) any
 
 	VisitVarStmt(stmt *Var) any
 	VisitExpressionStmt(stmt *Expression) any
@@ -21,7 +30,11 @@ type Visitor interface {
 	VisitBlockStmt(stmt *Block) any
 	VisitWhileStmt(stmt *While) any
 	VisitBreakStatement(stmt *Break) any
+	VisitContinueStmt(stmt *Continue) any
 	VisitFunctionStmt(stmt *Function) any
 	VisitReturnStmt(stmt *Return) any
 	VisitClassStmt(stmt *Class) any
+	VisitThrowStmt(stmt *Throw) any
+	VisitTryStmt(stmt *Try) any
+	VisitImportStmt(stmt *Import) any
 }
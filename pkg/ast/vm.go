@@ -0,0 +1,638 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// maxFrames bounds the call depth so runaway recursion is reported instead of exhausting memory
+const maxFrames = 1 << 16
+
+// VM runs prototypes produced by the Compiler. it shares an Interpreter's globals, natives
+// and io so scripts see the same environment whichever backend runs them.
+type VM struct {
+	interpreter  *Interpreter
+	stack        []any
+	frames       []callFrame
+	openUpvalues *upvalue
+	// handlers are the try statements being executed, innermost last
+	handlers []handler
+}
+
+// handler is where execution resumes when a runtime error is raised inside a try statement,
+// frames and stack are the sizes to unwind to and ip the address of the handler in the last frame
+type handler struct {
+	frames int
+	stack  int
+	ip     int
+}
+
+type callFrame struct {
+	closure *Closure
+	ip      int
+	// base is the stack slot holding the callee, its locals start there
+	base int
+}
+
+func NewVM(errorReporter func(error *RuntimeError), options ...Option) *VM {
+	return &VM{
+		interpreter: NewInterpreter(errorReporter, options...),
+		stack:       make([]any, 0, 256),
+	}
+}
+
+// DefineNative exposes a go function to scripts as a global function
+func (vm *VM) DefineNative(name string, arity int, fn NativeFn) {
+	vm.interpreter.DefineNative(name, arity, fn)
+}
+
+// DefineVariadicNative exposes a go function taking between minArity and maxArity arguments
+func (vm *VM) DefineVariadicNative(name string, minArity, maxArity int, fn NativeFn) {
+	vm.interpreter.DefineVariadicNative(name, minArity, maxArity, fn)
+}
+
+// DefineModule exposes every member of module under the module's name
+func (vm *VM) DefineModule(module *NativeModule) {
+	vm.interpreter.DefineModule(module)
+}
+
+// Global looks up a global variable, falling back to the natives
+func (vm *VM) Global(name string) (any, bool) {
+	return vm.interpreter.Global(name)
+}
+
+// SetGlobal defines or overwrites a global variable
+func (vm *VM) SetGlobal(name string, value any) {
+	vm.interpreter.SetGlobal(name, value)
+}
+
+// Interpret runs a compiled script and reports a runtime error the same way Interpreter.Interpret does
+func (vm *VM) Interpret(script *Prototype) error {
+	_, err := vm.Execute(script)
+	if err != nil {
+		vm.interpreter.errorReporter(err.(*RuntimeError))
+	}
+	return err
+}
+
+// Execute runs a compiled script and returns the value of its last expression statement
+func (vm *VM) Execute(script *Prototype) (value any, err error) {
+	return vm.Call(&Closure{prototype: script, module: vm.interpreter.main})
+}
+
+// Call invokes a callable lox value from go
+func (vm *VM) Call(callee any, arguments ...any) (value any, err error) {
+	frames, stack, handlers := len(vm.frames), len(vm.stack), len(vm.handlers)
+	defer func() {
+		if e := recover(); e != nil {
+			runtimeErr, ok := e.(*RuntimeError)
+			if ok {
+				vm.trace(runtimeErr)
+			}
+			// drop whatever the failed call left behind so the VM can be reused, e.g. by the REPL
+			vm.frames, vm.stack, vm.openUpvalues = vm.frames[:frames], vm.stack[:stack], nil
+			vm.handlers = vm.handlers[:handlers]
+			if !ok {
+				panic(e)
+			}
+			value, err = nil, runtimeErr
+		}
+	}()
+
+	vm.push(callee)
+	for _, argument := range arguments {
+		vm.push(argument)
+	}
+	if vm.callValue(callee, len(arguments), nil, scanner.Token{}) {
+		return vm.run(frames), nil
+	}
+	return vm.pop(), nil
+}
+
+// run executes frames until the one at baseFrames returns. a runtime error raised inside a try
+// statement unwinds to its handler, other errors are raised again to the caller.
+func (vm *VM) run(baseFrames int) any {
+	baseHandlers := len(vm.handlers)
+	for {
+		result, err := vm.dispatch(baseFrames)
+		if err == nil {
+			return result
+		}
+		if len(vm.handlers) == baseHandlers {
+			panic(err)
+		}
+		vm.trace(err)
+		h := vm.handlers[len(vm.handlers)-1]
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		vm.closeUpvalues(h.stack)
+		vm.frames, vm.stack = vm.frames[:h.frames], vm.stack[:h.stack]
+		vm.frames[h.frames-1].ip = h.ip
+		vm.push(err)
+	}
+}
+
+// dispatch executes instructions until the frame at baseFrames returns or a runtime error is raised
+func (vm *VM) dispatch(baseFrames int) (result any, err *RuntimeError) {
+	defer func() {
+		if e := recover(); e != nil {
+			runtimeErr, ok := e.(*RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			err = runtimeErr
+		}
+	}()
+
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := &frame.closure.prototype.chunk
+
+	readByte := func() byte {
+		b := chunk.code[frame.ip]
+		frame.ip++
+		return b
+	}
+	readU16 := func() int {
+		frame.ip += 2
+		return int(chunk.code[frame.ip-2])<<8 | int(chunk.code[frame.ip-1])
+	}
+	// token is the source of the instruction being executed
+	token := func() scanner.Token {
+		return chunk.tokens[frame.ip-1]
+	}
+
+	for {
+		switch OpCode(readByte()) {
+		case OP_CONSTANT:
+			vm.push(chunk.constants[readU16()])
+		case OP_NIL:
+			vm.push(nil)
+		case OP_TRUE:
+			vm.push(true)
+		case OP_FALSE:
+			vm.push(false)
+		case OP_POP:
+			vm.pop()
+		case OP_PICK:
+			vm.push(vm.peek(int(readByte())))
+		case OP_BURY:
+			depth := int(readByte())
+			top := len(vm.stack) - 1
+			value := vm.stack[top]
+			copy(vm.stack[top-depth+1:], vm.stack[top-depth:top])
+			vm.stack[top-depth] = value
+		case OP_GET_LOCAL:
+			vm.push(vm.stack[frame.base+int(readByte())])
+		case OP_SET_LOCAL:
+			vm.stack[frame.base+int(readByte())] = vm.peek(0)
+		case OP_GET_GLOBAL:
+			readU16()
+			vm.push(frame.closure.module.globals.get(token()))
+		case OP_DEFINE_GLOBAL:
+			readU16()
+			frame.closure.module.globals.declare(token(), vm.pop(), false)
+		case OP_DEFINE_CONST:
+			readU16()
+			frame.closure.module.globals.declare(token(), vm.pop(), true)
+		case OP_SET_GLOBAL:
+			readU16()
+			frame.closure.module.globals.assign(token(), vm.peek(0))
+		case OP_GET_UPVALUE:
+			upvalue := frame.closure.upvalues[readByte()]
+			if upvalue.closed {
+				vm.push(upvalue.value)
+			} else {
+				vm.push(vm.stack[upvalue.slot])
+			}
+		case OP_SET_UPVALUE:
+			upvalue := frame.closure.upvalues[readByte()]
+			if upvalue.closed {
+				upvalue.value = vm.peek(0)
+			} else {
+				vm.stack[upvalue.slot] = vm.peek(0)
+			}
+		case OP_GET_PROPERTY:
+			readU16()
+			vm.push(vm.getProperty(vm.pop(), token()))
+		case OP_SET_PROPERTY:
+			readU16()
+			value := vm.pop()
+			vm.setProperty(vm.pop(), token(), value)
+			vm.push(value)
+		case OP_GET_SUPER:
+			name := chunk.constants[readU16()].(string)
+			superclass := vm.pop().(*VMClass)
+			receiver := vm.pop()
+			method := superclass.findMethod(name)
+			if method == nil {
+				panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name), Token: token()})
+			}
+			vm.push(&boundMethod{receiver: receiver, method: method})
+		case OP_GET_INDEX:
+			index := vm.pop()
+			vm.push(vm.getIndex(vm.pop(), index, token()))
+		case OP_SET_INDEX:
+			value := vm.pop()
+			index := vm.pop()
+			vm.setIndex(vm.pop(), index, value, token())
+			vm.push(value)
+		case OP_EQUAL:
+			right := vm.pop()
+			vm.push(isEqual(vm.pop(), right))
+		case OP_GREATER:
+			left, right := vm.numberOperands(token())
+			vm.push(left > right)
+		case OP_GREATER_EQUAL:
+			left, right := vm.numberOperands(token())
+			vm.push(left >= right)
+		case OP_LESS:
+			left, right := vm.numberOperands(token())
+			vm.push(left < right)
+		case OP_LESS_EQUAL:
+			left, right := vm.numberOperands(token())
+			vm.push(left <= right)
+		case OP_ADD:
+			right := vm.pop()
+			vm.push(add(vm.pop(), right, token()))
+		case OP_SUBTRACT:
+			left, right := vm.numberOperands(token())
+			vm.push(left - right)
+		case OP_MULTIPLY:
+			left, right := vm.numberOperands(token())
+			vm.push(left * right)
+		case OP_DIVIDE:
+			left, right := vm.numberOperands(token())
+			if right == 0 {
+				panicWithToken(DivisionByZeroError, token())
+			}
+			vm.push(left / right)
+		case OP_MODULO:
+			left, right := vm.numberOperands(token())
+			if right == 0 {
+				panicWithToken(DivisionByZeroError, token())
+			}
+			vm.push(math.Mod(left, right))
+		case OP_NOT:
+			vm.push(!isTruthy(vm.pop()))
+		case OP_NEGATE:
+			vm.push(-checkNumber(vm.pop(), token()))
+		case OP_INCREMENT, OP_DECREMENT:
+			vm.push(step(vm.pop(), token()))
+		case OP_CHECK_BOOL:
+			if _, ok := vm.peek(0).(bool); !ok {
+				panic(&RuntimeError{Message: "ternary condition value is not a boolean", Token: token()})
+			}
+		case OP_PRINT:
+			fmt.Fprintln(vm.interpreter.stdout, vm.pop())
+		case OP_JUMP:
+			offset := readU16()
+			frame.ip += offset
+		case OP_JUMP_IF_FALSE:
+			offset := readU16()
+			if !isTruthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case OP_LOOP:
+			offset := readU16()
+			frame.ip -= offset
+		case OP_CALL:
+			argCount := int(readByte())
+			if vm.callValue(vm.peek(argCount), argCount, nil, token()) {
+				frame = &vm.frames[len(vm.frames)-1]
+				chunk = &frame.closure.prototype.chunk
+			}
+		case OP_CALL_ARGS:
+			positionalCount, namedCount := int(readByte()), int(readByte())
+			paren := token()
+			named := make([]namedArgument, namedCount)
+			values := vm.stack[len(vm.stack)-namedCount:]
+			for i := range named {
+				readU16()
+				named[i] = namedArgument{name: token(), value: values[i]}
+			}
+			vm.stack = vm.stack[:len(vm.stack)-namedCount]
+			argCount := vm.spread(positionalCount)
+			if vm.callValue(vm.peek(argCount), argCount, named, paren) {
+				frame = &vm.frames[len(vm.frames)-1]
+				chunk = &frame.closure.prototype.chunk
+			}
+		case OP_SPREAD:
+			vm.push(spreadArguments(spreadList(vm.pop(), token())))
+		case OP_DEFAULT:
+			slot, offset := int(readByte()), readU16()
+			if vm.stack[frame.base+slot] != absent {
+				frame.ip += offset
+			}
+		case OP_CLOSURE:
+			prototype := chunk.constants[readU16()].(*Prototype)
+			closure := &Closure{prototype: prototype, upvalues: make([]*upvalue, prototype.upvalueCount), module: frame.closure.module}
+			for i := range closure.upvalues {
+				isLocal, index := readByte(), int(readByte())
+				if isLocal == 1 {
+					closure.upvalues[i] = vm.captureUpvalue(frame.base + index)
+				} else {
+					closure.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OP_CLOSE_UPVALUE:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OP_RETURN:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == baseFrames {
+				return result, nil
+			}
+			vm.push(result)
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = &frame.closure.prototype.chunk
+		case OP_CLASS:
+			vm.push(newVMClass(chunk.constants[readU16()].(string)))
+		case OP_INHERIT:
+			superclass, ok := vm.peek(1).(*VMClass)
+			if !ok {
+				panic(&RuntimeError{Message: "superclass must be a class", Token: token()})
+			}
+			vm.pop().(*VMClass).superclass = superclass
+		case OP_METHOD:
+			name := chunk.constants[readU16()].(string)
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*VMClass).methods[name] = method
+		case OP_STATIC_METHOD:
+			name := chunk.constants[readU16()].(string)
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*VMClass).staticMethods[name] = method
+		case OP_LIST:
+			count := readU16()
+			elements := make([]any, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(NewLoxList(elements))
+		case OP_INTERPOLATE:
+			count := readU16()
+			var s strings.Builder
+			for _, part := range vm.stack[len(vm.stack)-count:] {
+				s.WriteString(stringify(part))
+			}
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(s.String())
+		case OP_MAP:
+			count := readU16()
+			m := NewLoxMap()
+			entries := vm.stack[len(vm.stack)-2*count:]
+			for i := 0; i < len(entries); i += 2 {
+				m.setAt(entries[i], entries[i+1])
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(m)
+		case OP_THROW:
+			throw(vm.pop(), token())
+		case OP_TRY:
+			offset := readU16()
+			vm.handlers = append(vm.handlers, handler{frames: len(vm.frames), stack: len(vm.stack), ip: frame.ip + offset})
+		case OP_END_TRY:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OP_CAUGHT:
+			vm.push(vm.pop().(*RuntimeError).caught())
+		case OP_RETHROW:
+			panic(vm.pop().(*RuntimeError))
+		case OP_ERROR:
+			panic(&RuntimeError{Message: chunk.constants[readU16()].(string), Token: token()})
+		case OP_IMPORT:
+			readU16()
+			module := vm.interpreter.modules.load(token(), frame.closure.module.path, vm.interpreter.builtins, vm.runModule)
+			// running the module may have grown the frames
+			frame = &vm.frames[len(vm.frames)-1]
+			vm.push(module)
+		}
+	}
+}
+
+// runModule compiles and runs the top level of an imported module
+func (vm *VM) runModule(stmts []Stmt, module *LoxModule, path scanner.Token) {
+	var collector diagnostics.Collector
+	script := NewCompiler(&collector).Compile(stmts)
+	if collector.HasErrors() {
+		panic(moduleError(module.path, collector.Diagnostics()[0], scanner.Token{}))
+	}
+	vm.push(&Closure{prototype: script, module: module})
+	vm.callValue(vm.peek(0), 0, nil, path)
+	vm.run(len(vm.frames) - 1)
+}
+
+// callValue calls callee with the argCount positional arguments on top of the stack and the named ones.
+// it returns true when a new frame was pushed, natives run right away and leave their result on the stack instead.
+func (vm *VM) callValue(callee any, argCount int, named []namedArgument, paren scanner.Token) bool {
+	switch callee := callee.(type) {
+	case *Closure:
+		return vm.call(callee, argCount, named, paren)
+	case *boundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.receiver
+		return vm.call(callee.method, argCount, named, paren)
+	case *VMClass:
+		instance := &VMInstance{class: callee, fields: make(map[string]any)}
+		vm.stack[len(vm.stack)-argCount-1] = instance
+		if init := callee.findMethod("init"); init != nil {
+			return vm.call(init, argCount, named, paren)
+		}
+		// without an init the class takes no arguments at all
+		vm.bind(callee, argCount, named, paren)
+		return false
+	case *NativeFunction:
+		argCount = vm.bind(callee, argCount, named, paren)
+		arguments := make([]any, argCount)
+		copy(arguments, vm.stack[len(vm.stack)-argCount:])
+		result := callee.callAt(vm.interpreter, arguments, paren)
+		vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+		vm.push(result)
+		return false
+	}
+	panic(&RuntimeError{Message: "can only call functions or classes", Token: paren})
+}
+
+func (vm *VM) call(closure *Closure, argCount int, named []namedArgument, paren scanner.Token) bool {
+	prototype := closure.prototype
+	if len(named) > 0 || prototype.minArity != prototype.maxArity {
+		argCount = vm.bind(prototype, argCount, named, paren)
+	} else if argCount != prototype.maxArity {
+		panic(&RuntimeError{Message: arityError(prototype.minArity, prototype.maxArity, argCount), Token: paren})
+	}
+	if len(vm.frames) == maxFrames {
+		panic(&RuntimeError{Message: "stack overflow", Token: paren})
+	}
+	vm.frames = append(vm.frames, callFrame{closure: closure, base: len(vm.stack) - argCount - 1})
+	return true
+}
+
+// bind replaces the argCount positional arguments on top of the stack with the ones
+// bindArguments matched to the parameters of callee, it returns how many there are now
+func (vm *VM) bind(callee signature, argCount int, named []namedArgument, paren scanner.Token) int {
+	positional := make([]any, argCount)
+	copy(positional, vm.stack[len(vm.stack)-argCount:])
+	arguments := bindArguments(callee, positional, named, paren)
+	vm.stack = append(vm.stack[:len(vm.stack)-argCount], arguments...)
+	return len(arguments)
+}
+
+// spreadArguments holds the elements of a list spread into a call until OP_CALL_ARGS expands them
+type spreadArguments []any
+
+// spread expands the lists spread into the count arguments on top of the stack, it returns the new argument count
+func (vm *VM) spread(count int) int {
+	arguments := make([]any, 0, count)
+	for _, argument := range vm.stack[len(vm.stack)-count:] {
+		if elements, ok := argument.(spreadArguments); ok {
+			arguments = append(arguments, elements...)
+		} else {
+			arguments = append(arguments, argument)
+		}
+	}
+	vm.stack = append(vm.stack[:len(vm.stack)-count], arguments...)
+	return len(arguments)
+}
+
+func (vm *VM) getProperty(object any, name scanner.Token) any {
+	switch object := object.(type) {
+	case *VMInstance:
+		if value, ok := object.fields[name.Lexeme]; ok {
+			return value
+		}
+		if method := object.class.findMethod(name.Lexeme); method != nil {
+			return &boundMethod{receiver: object, method: method}
+		}
+		panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
+	case *VMClass:
+		if value, ok := object.fields[name.Lexeme]; ok {
+			return value
+		}
+		if method := object.findStaticMethod(name.Lexeme); method != nil {
+			return &boundMethod{receiver: object, method: method}
+		}
+		panic(&RuntimeError{Message: fmt.Sprintf("undefined property %s", name.Lexeme), Token: name})
+	case *LoxList:
+		return object.get(name)
+	case *LoxMap:
+		return object.get(name)
+	case *NativeModule:
+		return object.get(name)
+	case *LoxError:
+		return object.get(name)
+	case *LoxModule:
+		return object.get(name)
+	case string:
+		return stringMethod(object, name)
+	}
+	panic(&RuntimeError{Message: "only instances have properties", Token: name})
+}
+
+func (vm *VM) setProperty(object any, name scanner.Token, value any) {
+	switch object := object.(type) {
+	case *VMInstance:
+		object.fields[name.Lexeme] = value
+	case *VMClass:
+		object.fields[name.Lexeme] = value
+	default:
+		panic(&RuntimeError{Message: "only instances have fields", Token: name})
+	}
+}
+
+func (vm *VM) getIndex(object, index any, bracket scanner.Token) any {
+	switch object := object.(type) {
+	case *LoxList:
+		return object.getAt(index, bracket)
+	case *LoxMap:
+		return object.getAt(index, bracket)
+	case string:
+		return stringAt(object, index, bracket)
+	}
+	panic(&RuntimeError{Message: "only lists, maps and strings can be indexed", Token: bracket})
+}
+
+func (vm *VM) setIndex(object, index, value any, bracket scanner.Token) {
+	switch object := object.(type) {
+	case *LoxList:
+		object.setAt(index, value, bracket)
+	case *LoxMap:
+		object.setAt(index, value)
+	default:
+		panic(&RuntimeError{Message: "only lists and maps support index assignment", Token: bracket})
+	}
+}
+
+// captureUpvalue reuses the open upvalue for slot if a closure already captured it
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	var previous *upvalue
+	current := vm.openUpvalues
+	for current != nil && current.slot > slot {
+		previous, current = current, current.next
+	}
+	if current != nil && current.slot == slot {
+		return current
+	}
+	created := &upvalue{slot: slot, next: current}
+	if previous == nil {
+		vm.openUpvalues = created
+	} else {
+		previous.next = created
+	}
+	return created
+}
+
+// closeUpvalues moves every variable at or above slot off the stack into its upvalue
+func (vm *VM) closeUpvalues(slot int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= slot {
+		upvalue := vm.openUpvalues
+		upvalue.value = vm.stack[upvalue.slot]
+		upvalue.closed = true
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+// numberOperands pops the two operands of an arithmetic or comparison operator
+func (vm *VM) numberOperands(operator scanner.Token) (float64, float64) {
+	right, left := vm.pop(), vm.pop()
+	return checkNumber(left, operator), checkNumber(right, operator)
+}
+
+func (vm *VM) push(value any) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() any {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) any {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// trace records the lox call stack on err before its frames are dropped, like Interpreter.trace does
+func (vm *VM) trace(err *RuntimeError) {
+	if err.Stack != nil {
+		return
+	}
+	err.Stack = make([]StackFrame, 0, len(vm.frames))
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		frame, prototype := vm.frames[i], vm.frames[i].closure.prototype
+		name := functionName(prototype.name)
+		if prototype.functionType == SCRIPT {
+			name = "script"
+		}
+		// frames not executing yet, e.g. when their arguments didn't match, aren't part of the trace
+		if frame.ip == 0 {
+			continue
+		}
+		if module := frame.closure.module; len(err.Stack) == 0 && module != vm.interpreter.main {
+			err.File, err.Source = module.path, module.source
+		}
+		err.Stack = append(err.Stack, StackFrame{Function: name, Class: prototype.class, File: frame.closure.module.path, Line: prototype.chunk.tokens[frame.ip-1].Line})
+	}
+}
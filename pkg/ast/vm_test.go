@@ -0,0 +1,118 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+func compile(t *testing.T, input string) *Prototype {
+	s := scanner.NewScanner(input, &diagnostics.Collector{})
+	reporter := diagnostics.ReporterFunc(func(d *diagnostics.Diagnostic) { t.Errorf("[line %d] Error: %s", d.Span.Line, d.Message) })
+	stmts, _ := NewParser(s.ScanTokens(), reporter).Parse()
+	NewResolver(reporter).Resolve(&stmts)
+	return NewCompiler(reporter).Compile(stmts)
+}
+
+func TestVMRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{"var a = 1;\nprint a + nil;", "operands can be numbers or strings", 2, 9},
+		{"fun f(a) {}\nf(1, 2);", "expected 1 arguments but got 2", 2, 7},
+		{"fun f() {\n  return missing;\n}\nf();", "undefined variable 'missing'", 2, 10},
+		{"var xs = [1];\nxs[3] = 1;", "list index 3 out of range [0, 1)", 2, 3},
+		{"var s = \"héllo\";\ns[5];", "string index 5 out of range [0, 5)", 2, 2},
+		{"\"abc\".substr(1, 3);", "substring length must be an integer in [0, 2]", 1, 18},
+		{"\"abc\".substr(0, 1e30);", "substring length must be an integer in [0, 3]", 1, 21},
+		{"var s = \"a\";\ns++;", "operand of '++' must be a number", 2, 2},
+		{"fun f() {}\n--f();", "invalid target for '--', only variables, fields and indexed elements can be assigned to", 2, 1},
+		{"var n = 1;\nn /= 0;", "division by zero", 2, 3},
+		{"class A {}\nA().b;", "undefined property b", 2, 5},
+		{"fun f() { f(); }\nf();", "stack overflow", 1, 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			vm := NewVM(func(*RuntimeError) {})
+			_, err := vm.Execute(compile(t, tt.input))
+			e, ok := err.(*RuntimeError)
+			if !ok {
+				t.Fatalf("Execute(%q) error = %v, want a runtime error", tt.input, err)
+			}
+			if e.Message != tt.message || e.Token.Line != tt.line || e.Token.Column != tt.column {
+				t.Errorf("Execute(%q) = %q at %d:%d, want %q at %d:%d", tt.input, e.Message, e.Token.Line, e.Token.Column, tt.message, tt.line, tt.column)
+			}
+			// the VM is left in a usable state, the REPL keeps going after errors
+			if value, err := vm.Execute(compile(t, "1 + 2;")); err != nil || value != float64(3) {
+				t.Errorf("Execute after error = %v, %v, want 3", value, err)
+			}
+		})
+	}
+}
+
+func TestVMCall(t *testing.T) {
+	vm := NewVM(func(*RuntimeError) {})
+	vm.Execute(compile(t, `
+	var base = 10;
+	fun add(a, b) { return base + a + b; }
+	class Counter { init(start) { this.count = start; } }
+	`))
+	add, _ := vm.Global("add")
+	if value, err := vm.Call(add, 1.0, 2.0); err != nil || value != float64(13) {
+		t.Errorf("Call(add, 1, 2) = %v, %v, want 13", value, err)
+	}
+	counter, _ := vm.Global("Counter")
+	if value, err := vm.Call(counter, 5.0); err != nil || value.(*VMInstance).fields["count"] != float64(5) {
+		t.Errorf("Call(Counter, 5) = %v, %v, want an instance counting from 5", value, err)
+	}
+	if _, err := vm.Call(add, 1.0); err == nil {
+		t.Error("Call(add, 1) succeeded with a missing argument")
+	}
+}
+
+func TestVMGoPanic(t *testing.T) {
+	vm := NewVM(func(*RuntimeError) {})
+	vm.DefineNative("crash", 0, func(arguments []any) (any, error) {
+		panic("crash")
+	})
+	vm.Execute(compile(t, "fun f() { crash(); }"))
+	f, _ := vm.Global("f")
+	func() {
+		defer func() {
+			if e := recover(); e != "crash" {
+				t.Errorf("Call(f) panicked with %v, want crash", e)
+			}
+		}()
+		vm.Call(f)
+	}()
+	if len(vm.frames) != 0 || len(vm.stack) != 0 {
+		t.Errorf("Call(f) left %d frames and %d values behind", len(vm.frames), len(vm.stack))
+	}
+	if value, err := vm.Execute(compile(t, "1 + 2;")); err != nil || value != float64(3) {
+		t.Errorf("Execute after panic = %v, %v, want 3", value, err)
+	}
+}
+
+func TestCompilerLimits(t *testing.T) {
+	var locals strings.Builder
+	locals.WriteString("fun f() {\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&locals, "var v%d = %d;\n", i, i)
+	}
+	locals.WriteString("}\n")
+
+	var collector diagnostics.Collector
+	s := scanner.NewScanner(locals.String(), &collector)
+	stmts, _ := NewParser(s.ScanTokens(), &collector).Parse()
+	NewCompiler(&collector).Compile(stmts)
+	ds := collector.Diagnostics()
+	if len(ds) == 0 || ds[0].Code != diagnostics.TooManyLocals || ds[0].Span.Line != 257 {
+		t.Errorf("compiling 300 locals reported %v, want %s at line 257", ds, diagnostics.TooManyLocals)
+	}
+}
@@ -0,0 +1,40 @@
+package diagnostics
+
+// Code is a stable identifier for a kind of diagnostic,
+// E00xx come from the scanner, E01xx from the parser, E02xx from the resolver
+// and E03xx from the bytecode compiler.
+type Code string
+
+const (
+	UnexpectedCharacter  Code = "E0001"
+	UnterminatedString   Code = "E0002"
+	UnterminatedComment  Code = "E0003"
+	InvalidNumber        Code = "E0004"
+	InvalidEscape        Code = "E0005"
+	ExpectedToken        Code = "E0100"
+	ExpectedExpression   Code = "E0101"
+	MissingOperand       Code = "E0102"
+	InvalidAssignment    Code = "E0103"
+	BreakOutsideLoop     Code = "E0104"
+	TooManyParameters    Code = "E0105"
+	TooManyArguments     Code = "E0106"
+	ContinueOutsideLoop  Code = "E0107"
+	UndefinedLabel       Code = "E0108"
+	InvalidParameter     Code = "E0109"
+	InvalidArgument      Code = "E0110"
+	SelfReference        Code = "E0200"
+	ReturnOutsideFunc    Code = "E0201"
+	ReturnFromInit       Code = "E0202"
+	ThisOutsideClass     Code = "E0203"
+	ThisInStaticMethod   Code = "E0204"
+	SuperOutsideClass    Code = "E0205"
+	SuperWithoutSubclass Code = "E0206"
+	SuperInStaticMethod  Code = "E0207"
+	SelfInheritance      Code = "E0208"
+	ConstantAssignment   Code = "E0209"
+	ConstantRedeclared   Code = "E0210"
+	TooManyConstants     Code = "E0300"
+	TooManyLocals        Code = "E0301"
+	TooManyUpvalues      Code = "E0302"
+	JumpTooLarge         Code = "E0303"
+)
@@ -0,0 +1,48 @@
+package diagnostics
+
+import "sort"
+
+// Reporter receives the diagnostics found by the scanner, parser and resolver
+type Reporter interface {
+	Report(d *Diagnostic)
+}
+
+// ReporterFunc adapts a plain function to the Reporter interface
+type ReporterFunc func(d *Diagnostic)
+
+func (f ReporterFunc) Report(d *Diagnostic) {
+	f(d)
+}
+
+// Collector keeps every reported diagnostic so they can all be shown at once
+type Collector struct {
+	diagnostics []*Diagnostic
+}
+
+func (c *Collector) Report(d *Diagnostic) {
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// Diagnostics returns everything reported so far ordered by position in the source
+func (c *Collector) Diagnostics() []*Diagnostic {
+	sorted := make([]*Diagnostic, len(c.diagnostics))
+	copy(sorted, c.diagnostics)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Span.Start < sorted[j].Span.Start
+	})
+	return sorted
+}
+
+func (c *Collector) HasErrors() bool {
+	for _, d := range c.diagnostics {
+		if d.Severity == ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset drops everything collected, e.g. between two lines of a REPL
+func (c *Collector) Reset() {
+	c.diagnostics = nil
+}
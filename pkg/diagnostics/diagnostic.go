@@ -0,0 +1,52 @@
+// Package diagnostics describes problems found in lox source code and renders them
+// with the offending line quoted and underlined.
+package diagnostics
+
+type Severity int
+
+const (
+	ERROR Severity = iota
+	WARNING
+)
+
+func (s Severity) String() string {
+	if s == WARNING {
+		return "warning"
+	}
+	return "error"
+}
+
+type Diagnostic struct {
+	Severity Severity
+	// Code identifies the kind of problem, see codes.go. it is empty for runtime errors
+	Code    Code
+	Message string
+	Span    Span
+	// Notes add context, Suggestions tell the user how to fix the problem
+	Notes       []string
+	Suggestions []string
+}
+
+func (d *Diagnostic) Error() string {
+	return d.Message
+}
+
+// WithNote returns d after adding a note, to allow building diagnostics inline
+func (d *Diagnostic) WithNote(note string) *Diagnostic {
+	d.Notes = append(d.Notes, note)
+	return d
+}
+
+// WithSuggestion returns d after adding a suggestion, to allow building diagnostics inline
+func (d *Diagnostic) WithSuggestion(suggestion string) *Diagnostic {
+	d.Suggestions = append(d.Suggestions, suggestion)
+	return d
+}
+
+func Error(code Code, span Span, message string) *Diagnostic {
+	return &Diagnostic{Severity: ERROR, Code: code, Message: message, Span: span}
+}
+
+func Warning(code Code, span Span, message string) *Diagnostic {
+	return &Diagnostic{Severity: WARNING, Code: code, Message: message, Span: span}
+}
@@ -0,0 +1,97 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Render writes d in the following format, filename may be empty:
+//
+//	error[E0101]: expect expression
+//	 --> main.lox:2:9
+//	  |
+//	2 | var b = ;
+//	  |         ^
+//	  = note: ...
+//	  = help: ...
+func Render(w io.Writer, filename, source string, d *Diagnostic) {
+	if d.Code != "" {
+		fmt.Fprintf(w, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	} else {
+		fmt.Fprintf(w, "%s: %s\n", d.Severity, d.Message)
+	}
+
+	if d.Span.IsZero() {
+		renderFooter(w, "", d)
+		return
+	}
+
+	location := fmt.Sprintf("%d:%d", d.Span.Line, d.Span.Column)
+	if filename != "" {
+		location = filename + ":" + location
+	}
+	gutter := strings.Repeat(" ", len(strconv.Itoa(d.Span.Line)))
+	fmt.Fprintf(w, "%s--> %s\n", gutter, location)
+
+	if line, underline, ok := quote(source, d.Span); ok {
+		fmt.Fprintf(w, "%s |\n", gutter)
+		fmt.Fprintf(w, "%d | %s\n", d.Span.Line, line)
+		fmt.Fprintf(w, "%s | %s\n", gutter, underline)
+	}
+	renderFooter(w, gutter, d)
+}
+
+func renderFooter(w io.Writer, gutter string, d *Diagnostic) {
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", gutter, note)
+	}
+	for _, suggestion := range d.Suggestions {
+		fmt.Fprintf(w, "%s = help: %s\n", gutter, suggestion)
+	}
+}
+
+// RenderAll renders every diagnostic in order separated by blank lines
+func RenderAll(w io.Writer, filename, source string, diagnostics []*Diagnostic) {
+	for i, d := range diagnostics {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		Render(w, filename, source, d)
+	}
+}
+
+// quote returns the source line span starts on and a caret underline of the span
+func quote(source string, span Span) (line string, underline string, ok bool) {
+	if span.Start > len(source) {
+		return "", "", false
+	}
+	lineStart := strings.LastIndexByte(source[:span.Start], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[span.Start:], '\n'); i >= 0 {
+		lineEnd = span.Start + i
+	}
+	// spans running past the end of the line are underlined up to the line end,
+	// with a caret per rune
+	end := span.End
+	if end > lineEnd {
+		end = lineEnd
+	}
+	if end < span.Start {
+		end = span.Start
+	}
+	width := utf8.RuneCountInString(source[span.Start:end])
+	if width < 1 {
+		width = 1
+	}
+	// keep tabs so the carets line up with the quoted line
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, source[lineStart:span.Start])
+	return source[lineStart:lineEnd], padding + strings.Repeat("^", width), true
+}
@@ -0,0 +1,107 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	source := "var a = 1;\n\tvar b = ;\n"
+	tests := []struct {
+		name     string
+		filename string
+		d        *Diagnostic
+		expected string
+	}{
+		{
+			"snippet",
+			"main.lox",
+			Error(ExpectedExpression, Span{Line: 2, Column: 10, Start: 20, End: 21}, "expect expression").
+				WithNote("a note").WithSuggestion("a hint"),
+			"error[E0101]: expect expression\n" +
+				" --> main.lox:2:10\n" +
+				"  |\n" +
+				"2 | \tvar b = ;\n" +
+				"  | \t        ^\n" +
+				"  = note: a note\n" +
+				"  = help: a hint\n",
+		},
+		{
+			"multi byte span",
+			"",
+			Error(UnexpectedCharacter, Span{Line: 1, Column: 5, Start: 4, End: 9}, "oops"),
+			"error[E0001]: oops\n" +
+				" --> 1:5\n" +
+				"  |\n" +
+				"1 | var a = 1;\n" +
+				"  |     ^^^^^\n",
+		},
+		{
+			"no span",
+			"main.lox",
+			Warning("", Span{}, "something off").WithNote("a note"),
+			"warning: something off\n" +
+				" = note: a note\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			Render(&out, tt.filename, source, tt.d)
+			if out.String() != tt.expected {
+				t.Errorf("Render() =\n%s\nwant\n%s", out.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderUnicode(t *testing.T) {
+	source := "var café = \"日本\" ^;"
+	d := Error(UnexpectedCharacter, Span{Line: 1, Column: 17, Start: 21, End: 22}, "unexpected character '^'")
+	expected := "error[E0001]: unexpected character '^'\n" +
+		" --> 1:17\n" +
+		"  |\n" +
+		"1 | var café = \"日本\" ^;\n" +
+		"  |                 ^\n"
+	var out strings.Builder
+	Render(&out, "", source, d)
+	if out.String() != expected {
+		t.Errorf("Render() =\n%s\nwant\n%s", out.String(), expected)
+	}
+
+	// a span over multibyte characters gets a caret per character
+	out.Reset()
+	Render(&out, "", source, Error(UnexpectedCharacter, Span{Line: 1, Column: 12, Start: 12, End: 20}, "oops"))
+	if want := "  |            ^^^^\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("Render() =\n%s\nwant it to end with\n%s", out.String(), want)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	var c Collector
+	if c.HasErrors() {
+		t.Error("empty collector has errors")
+	}
+	c.Report(Warning("", Span{Start: 10}, "second"))
+	c.Report(Warning("", Span{Start: 2}, "first"))
+	if c.HasErrors() {
+		t.Error("warnings counted as errors")
+	}
+	c.Report(Error("", Span{Start: 20}, "third"))
+	if !c.HasErrors() {
+		t.Error("expected errors")
+	}
+
+	var messages []string
+	for _, d := range c.Diagnostics() {
+		messages = append(messages, d.Message)
+	}
+	if got := strings.Join(messages, ","); got != "first,second,third" {
+		t.Errorf("Diagnostics() = %s, want first,second,third", got)
+	}
+
+	c.Reset()
+	if len(c.Diagnostics()) != 0 {
+		t.Error("Reset() kept diagnostics")
+	}
+}
@@ -0,0 +1,24 @@
+package diagnostics
+
+// Span locates a piece of source code. Line and Column are where it starts, Column counting runes,
+// Start and End are byte offsets into the source with End being exclusive.
+// the zero Span is used for code the parser synthesizes, e.g. the condition of `for (;;)`
+type Span struct {
+	Line, Column int
+	Start, End   int
+}
+
+func (s Span) IsZero() bool {
+	return s == Span{}
+}
+
+// To returns the span starting where s starts and ending where other ends
+func (s Span) To(other Span) Span {
+	if s.IsZero() {
+		return other
+	}
+	if other.IsZero() || other.End < s.End {
+		return s
+	}
+	return Span{Line: s.Line, Column: s.Column, Start: s.Start, End: other.End}
+}
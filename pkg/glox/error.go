@@ -0,0 +1,53 @@
+package glox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+)
+
+// Phase tells which step of running a script produced an error
+type Phase int
+
+const (
+	SCAN Phase = iota
+	PARSE
+	RESOLVE
+	RUNTIME
+)
+
+func (p Phase) String() string {
+	switch p {
+	case SCAN:
+		return "scan"
+	case PARSE:
+		return "parse"
+	case RESOLVE:
+		return "resolve"
+	default:
+		return "runtime"
+	}
+}
+
+// Error is a diagnostic tagged with the phase that found it.
+// its Span is zero when it isn't tied to the source, e.g. calling an undefined function from go
+type Error struct {
+	Phase Phase
+	*diagnostics.Diagnostic
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("[line %d:%d] %s error: %s", err.Span.Line, err.Span.Column, err.Phase, err.Message)
+}
+
+// ErrorList holds every static error reported while compiling a script
+type ErrorList []*Error
+
+func (errs ErrorList) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
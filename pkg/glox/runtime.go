@@ -0,0 +1,163 @@
+// Package glox embeds the lox interpreter in go programs.
+//
+//	rt := glox.New()
+//	rt.SetGlobal("limit", 10)
+//	_, err := rt.Eval(`fun double(x) { return x * 2; }`)
+//	value, err := rt.Call("double", 21)
+package glox
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fadyZohdy/gLox/pkg/ast"
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+	"github.com/fadyZohdy/gLox/pkg/scanner"
+)
+
+// Value is any lox value: float64, string, bool, nil or one of the ast runtime objects
+type Value = any
+
+// Runtime keeps its globals across Eval calls, so a script can be loaded once and then called into.
+type Runtime struct {
+	interpreter *ast.Interpreter
+}
+
+// Option configures the interpreter behind a Runtime
+type Option = ast.Option
+
+// WithStdout captures everything scripts print into w
+func WithStdout(w io.Writer) Option {
+	return ast.WithStdout(w)
+}
+
+// WithStdin makes scripts read their input from r
+func WithStdin(r io.Reader) Option {
+	return ast.WithStdin(r)
+}
+
+// WithFileName names the scripts in the stack traces of runtime errors
+func WithFileName(name string) Option {
+	return ast.WithFileName(name)
+}
+
+// WithSearchPath adds directories where imported modules are looked for
+func WithSearchPath(dirs ...string) Option {
+	return ast.WithSearchPath(dirs...)
+}
+
+func New(options ...Option) *Runtime {
+	return &Runtime{interpreter: ast.NewInterpreter(func(*ast.RuntimeError) {}, options...)}
+}
+
+// Eval runs source and returns the value of its last statement when it is an expression.
+// static errors are returned together as an ErrorList, runtime errors as an *Error.
+func (r *Runtime) Eval(source string) (Value, error) {
+	var errs ErrorList
+	// tags every reported diagnostic with the phase that found it
+	reporter := func(phase Phase) diagnostics.Reporter {
+		return diagnostics.ReporterFunc(func(d *diagnostics.Diagnostic) {
+			errs = append(errs, &Error{Phase: phase, Diagnostic: d})
+		})
+	}
+
+	tokens := scanner.NewScanner(source, reporter(SCAN)).ScanTokens()
+
+	stmts, err := ast.NewParser(tokens, reporter(PARSE)).Parse()
+	if err != nil && len(errs) == 0 {
+		errs = append(errs, &Error{Phase: PARSE, Diagnostic: diagnostics.Error(diagnostics.ExpectedToken, diagnostics.Span{}, err.Error())})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	ast.NewResolver(reporter(RESOLVE)).Resolve(&stmts)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	value, err := r.interpreter.Execute(stmts)
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+	return value, nil
+}
+
+// Call calls the global function or class named name with args
+func (r *Runtime) Call(name string, args ...Value) (Value, error) {
+	callee, ok := r.interpreter.Global(name)
+	if !ok {
+		return nil, &Error{Phase: RUNTIME, Diagnostic: diagnostics.Error("", diagnostics.Span{}, fmt.Sprintf("undefined variable '%s'", name))}
+	}
+	arguments := make([]any, len(args))
+	for i, arg := range args {
+		arguments[i] = toLox(arg)
+	}
+	value, err := r.interpreter.Call(callee, arguments...)
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+	return value, nil
+}
+
+func (r *Runtime) GetGlobal(name string) (Value, bool) {
+	return r.interpreter.Global(name)
+}
+
+// SetGlobal defines a global visible to every script evaluated afterwards.
+// go numeric types are converted to lox numbers.
+func (r *Runtime) SetGlobal(name string, value Value) {
+	r.interpreter.SetGlobal(name, toLox(value))
+}
+
+// DefineNative exposes a go function to scripts, see ast.Interpreter.DefineNative
+func (r *Runtime) DefineNative(name string, arity int, fn ast.NativeFn) {
+	r.interpreter.DefineNative(name, arity, fn)
+}
+
+// DefineVariadicNative exposes a go function taking a range of arguments, see ast.Interpreter.DefineVariadicNative
+func (r *Runtime) DefineVariadicNative(name string, minArity, maxArity int, fn ast.NativeFn) {
+	r.interpreter.DefineVariadicNative(name, minArity, maxArity, fn)
+}
+
+func runtimeError(err error) *Error {
+	if e, ok := err.(*ast.RuntimeError); ok {
+		return &Error{Phase: RUNTIME, Diagnostic: e.Diagnostic()}
+	}
+	return &Error{Phase: RUNTIME, Diagnostic: diagnostics.Error("", diagnostics.Span{}, err.Error())}
+}
+
+// toLox converts go values to their lox representation, lox only knows about float64 numbers
+func toLox(value Value) any {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []any:
+		elements := make([]any, len(v))
+		for i, element := range v {
+			elements[i] = toLox(element)
+		}
+		return ast.NewLoxList(elements)
+	}
+	return value
+}
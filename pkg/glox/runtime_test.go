@@ -0,0 +1,162 @@
+package glox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Value
+	}{
+		{"1 + 2;", float64(3)},
+		{"var x = 1;", nil},
+		{`"a" + "b";`, "ab"},
+		{"fun f() { return 4; } f() * 2;", float64(8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := New().Eval(tt.input)
+			if err != nil {
+				t.Fatalf("Eval(%s) returned error %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Eval(%s) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		phase Phase
+		line  int
+		col   int
+	}{
+		{"var x = ;", PARSE, 1, 9},
+		{"\n\"unterminated", SCAN, 2, 1},
+		{"{ var a = a; }", RESOLVE, 1, 11},
+		{"\n1 / 0;", RUNTIME, 2, 3},
+		{"undefined;", RUNTIME, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := New().Eval(tt.input)
+			var e *Error
+			var list ErrorList
+			if errors.As(err, &list) {
+				e = list[0]
+			} else if !errors.As(err, &e) {
+				t.Fatalf("Eval(%s) error = %v, want a glox error", tt.input, err)
+			}
+			if e.Phase != tt.phase || e.Span.Line != tt.line || e.Span.Column != tt.col {
+				t.Errorf("Eval(%s) error = %v, want %s error at %d:%d", tt.input, e, tt.phase, tt.line, tt.col)
+			}
+		})
+	}
+}
+
+func TestGlobalsAndCall(t *testing.T) {
+	rt := New()
+	rt.SetGlobal("base", 10)
+	if _, err := rt.Eval("fun add(x) { return base + x; } var last;"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rt.Call("add", 5)
+	if err != nil || got != float64(15) {
+		t.Errorf("Call(add, 5) = %v, %v, want 15", got, err)
+	}
+
+	if _, err := rt.Call("add"); err == nil {
+		t.Errorf("Call(add) with missing argument should fail")
+	}
+	if _, err := rt.Call("missing"); err == nil {
+		t.Errorf("Call(missing) should fail")
+	}
+
+	if _, err := rt.Eval("last = add(1);"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := rt.GetGlobal("last"); !ok || got != float64(11) {
+		t.Errorf("GetGlobal(last) = %v, %v, want 11", got, ok)
+	}
+}
+
+func TestDefineNative(t *testing.T) {
+	rt := New()
+	rt.DefineNative("greet", 1, func(arguments []any) (any, error) {
+		return "hello " + arguments[0].(string), nil
+	})
+	got, err := rt.Eval(`greet("lox");`)
+	if err != nil || got != "hello lox" {
+		t.Errorf(`greet("lox") = %v, %v, want "hello lox"`, got, err)
+	}
+}
+
+func TestGoPanics(t *testing.T) {
+	rt := New()
+	rt.DefineNative("crash", 0, func(arguments []any) (any, error) {
+		panic("crash")
+	})
+	if _, err := rt.Eval(`fun f() { crash(); }`); err != nil {
+		t.Fatal(err)
+	}
+	// only lox runtime errors are returned, anything else a native panics with is a bug the embedder should see
+	for name, run := range map[string]func(){
+		"Eval": func() { rt.Eval(`crash();`) },
+		"Call": func() { rt.Call("f") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if e := recover(); e != "crash" {
+					t.Errorf("%s panicked with %v, want crash", name, e)
+				}
+			}()
+			run()
+		})
+	}
+}
+
+func TestStdout(t *testing.T) {
+	var out bytes.Buffer
+	rt := New(WithStdout(&out))
+	if _, err := rt.Eval(`print "a"; print 1 + 1; io.write("b", 3); io.writeln("!");`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "a\n2\nb 3!\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestConcurrentRuntimes(t *testing.T) {
+	// runtimes share no state, each goroutine raises its error from a different depth
+	// and must get back its own trace
+	var wg sync.WaitGroup
+	for depth := 0; depth < 4; depth++ {
+		wg.Add(1)
+		go func(depth int) {
+			defer wg.Done()
+			source := "fun f0() { return 1 / 0; }\n"
+			for i := 1; i <= depth; i++ {
+				source += fmt.Sprintf("fun f%d() { return f%d(); }\n", i, i-1)
+			}
+			source += fmt.Sprintf("f%d();", depth)
+			r := New()
+			for i := 0; i < 50; i++ {
+				_, err := r.Eval(source)
+				var e *Error
+				if !errors.As(err, &e) || e.Phase != RUNTIME || e.Span.Line != 1 || len(e.Notes) != depth+2 {
+					t.Errorf("Eval error = %v, want a runtime error at line 1 with %d frames", err, depth+2)
+					return
+				}
+			}
+		}(depth)
+	}
+	wg.Wait()
+}
@@ -1,8 +1,13 @@
 package scanner
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 )
 
 type Scanner struct {
@@ -10,19 +15,37 @@ type Scanner struct {
 	tokens []Token
 	// start of current token not file
 	start, current, line int
-	error_reporter       func(int, string)
+	// byte offset where the current line begins, used to compute columns
+	lineStart int
+	// lineColumns caches the column of lineColumnsAt, so counting runes doesn't restart at every token
+	lineColumns, lineColumnsAt int
+	// position of the current token, tokens spanning multiple lines are reported where they start
+	startLine, startColumn int
+	// interpolations has an entry per "${" being scanned, counting the braces opened inside it,
+	// the "}" closing it resumes the string
+	interpolations []int
+	reporter       diagnostics.Reporter
 }
 
-func NewScanner(source string, error func(int, string)) *Scanner {
-	return &Scanner{source: source, error_reporter: error, line: 1, tokens: make([]Token, 0, len(source))}
+func NewScanner(source string, reporter diagnostics.Reporter) *Scanner {
+	return &Scanner{source: source, reporter: reporter, line: 1, lineColumns: 1, tokens: make([]Token, 0, len(source))}
 }
 
 func (s *Scanner) ScanTokens() []Token {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startLine = s.line
+		s.startColumn = s.column()
 		s.scanToken()
 	}
-	s.tokens = append(s.tokens, Token{Type: EOF, Line: s.line})
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = s.column()
+	if len(s.interpolations) > 0 {
+		s.reporter.Report(diagnostics.Error(diagnostics.UnterminatedString, s.span(), "unterminated string interpolation").
+			WithSuggestion("close the interpolation with '}'"))
+	}
+	s.addToken(EOF)
 	return s.tokens
 }
 
@@ -36,31 +59,64 @@ func (s *Scanner) scanToken() {
 	case ')':
 		s.addToken(RIGHT_PAREN)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		s.addToken(LEFT_BRACE)
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				s.scan_string()
+				return
+			}
+			s.interpolations[n-1]--
+		}
 		s.addToken(RIGHT_BRACE)
+	case '[':
+		s.addToken(LEFT_BRACKET)
+	case ']':
+		s.addToken(RIGHT_BRACKET)
 	case ',':
 		s.addToken(COMMA)
 	case '.':
-		s.addToken(DOT)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(ELLIPSIS)
+		} else {
+			s.addToken(DOT)
+		}
 	case '-':
 		if s.match('-') {
 			s.addToken(DECREMENT)
+		} else if s.match('=') {
+			s.addToken(MINUS_EQUAL)
 		} else {
 			s.addToken(MINUS)
 		}
 	case '+':
 		if s.match('+') {
 			s.addToken(INCREMENT)
+		} else if s.match('=') {
+			s.addToken(PLUS_EQUAL)
 		} else {
 			s.addToken(PLUS)
 		}
 	case ';':
 		s.addToken(SEMICOLON)
 	case '*':
-		s.addToken(STAR)
+		if s.match('=') {
+			s.addToken(STAR_EQUAL)
+		} else {
+			s.addToken(STAR)
+		}
 	case '%':
-		s.addToken(MODULO)
+		if s.match('=') {
+			s.addToken(MODULO_EQUAL)
+		} else {
+			s.addToken(MODULO)
+		}
 	case '?':
 		s.addToken(QUESTION_MARK)
 	case ':':
@@ -74,6 +130,8 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			s.addToken(EQUAL_EQUAL)
+		} else if s.match('>') {
+			s.addToken(ARROW)
 		} else {
 			s.addToken(EQUAL)
 		}
@@ -96,66 +154,238 @@ func (s *Scanner) scanToken() {
 			}
 		} else if s.match('*') {
 			s.scan_multiline_comment()
+		} else if s.match('=') {
+			s.addToken(SLASH_EQUAL)
 		} else {
 			s.addToken(SLASH)
 		}
 	case ' ', '\r', '\t':
 		break
 	case '\n':
-		s.line++
+		s.newline()
 	case '"':
 		s.scan_string()
 	default:
-		if unicode.IsDigit(c) {
+		if isDigit(c) {
 			s.scan_number()
-		} else if unicode.IsLetter(c) {
+		} else if isIdentifierStart(c) {
 			s.scan_identifier()
+		} else if c == utf8.RuneError {
+			s.reporter.Report(diagnostics.Error(diagnostics.UnexpectedCharacter, s.span(), "invalid UTF-8 encoding"))
 		} else {
-			s.error_reporter(s.line, "Unexpected character '"+string(c)+"'")
+			s.reporter.Report(diagnostics.Error(diagnostics.UnexpectedCharacter, s.span(), "unexpected character '"+string(c)+"'"))
 		}
 	}
 
 }
 
+// scan_string scans a string literal, or the rest of one after an interpolated expression.
+// a "${" ends the token early as an INTERPOLATION and the expression after it is scanned as usual.
 func (s *Scanner) scan_string() {
+	var value strings.Builder
 	for !s.isAtEnd() && s.peek() != '"' {
-		if s.peek() == '\n' {
-			s.line++
+		c := s.advance()
+		switch {
+		case c == '\\':
+			s.scan_escape(&value)
+		case c == '$' && s.peek() == '{':
+			s.advance()
+			s.interpolations = append(s.interpolations, 0)
+			s.addTokenWithLiteral(INTERPOLATION, value.String())
+			return
+		default:
+			if c == '\n' {
+				s.newline()
+			}
+			value.WriteRune(c)
 		}
-		s.advance()
 	}
 
 	// we reached the end and didn't encounter the closing quote
 	if s.isAtEnd() {
-		s.error_reporter(s.line, "Unterminated string.")
+		s.reporter.Report(diagnostics.Error(diagnostics.UnterminatedString, s.span(), "unterminated string").
+			WithSuggestion("add a closing '\"'"))
 		return
 	}
 
 	// the closing "
 	s.advance()
 
-	value := s.source[s.start+1 : s.current-1]
-	s.addTokenWithLiteral(STRING, value)
+	s.addTokenWithLiteral(STRING, value.String())
 }
 
-func (s *Scanner) scan_number() {
-	for unicode.IsDigit(s.peek()) {
-		s.advance()
+var escapes = map[rune]rune{'n': '\n', 't': '\t', 'r': '\r', '0': 0, '"': '"', '\\': '\\', '$': '$'}
+
+// scan_escape writes the character escaped by the sequence following a '\\'
+func (s *Scanner) scan_escape(value *strings.Builder) {
+	start, column := s.current-1, s.column()-1
+	invalid := func(message string) {
+		span := diagnostics.Span{Line: s.line, Column: column, Start: start, End: s.current}
+		s.reporter.Report(diagnostics.Error(diagnostics.InvalidEscape, span, message).
+			WithNote("valid escapes are \\n \\t \\r \\0 \\\" \\\\ \\$ and \\u{XXXX}"))
+	}
+	if s.isAtEnd() {
+		return
+	}
+	c := s.advance()
+	if escaped, ok := escapes[c]; ok {
+		value.WriteRune(escaped)
+		return
+	}
+	if c != 'u' {
+		if c == '\n' {
+			s.newline()
+		}
+		invalid("invalid escape sequence '\\" + string(c) + "'")
+		return
 	}
 
-	if s.peek() == '.' && unicode.IsDigit(s.peekNext()) {
+	// \u{1F600} takes 1 to 6 hex digits
+	if !s.match('{') {
+		invalid("expect '{' after '\\u'")
+		return
+	}
+	digits := s.current
+	for isHexDigit(s.peek()) {
 		s.advance()
+	}
+	hex := s.source[digits:s.current]
+	if !s.match('}') || len(hex) == 0 || len(hex) > 6 {
+		invalid("unicode escapes must be 1 to 6 hex digits between '{' and '}'")
+		return
+	}
+	code, _ := strconv.ParseUint(hex, 16, 32)
+	if code > unicode.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+		invalid("'" + hex + "' is not a valid unicode code point")
+		return
+	}
+	value.WriteRune(rune(code))
+}
 
-		for unicode.IsDigit(s.peek()) {
+func isHexDigit(c rune) bool {
+	return unicode.Is(unicode.ASCII_Hex_Digit, c)
+}
+
+var radixes = map[rune]struct {
+	base int
+	name string
+}{'x': {16, "hexadecimal"}, 'b': {2, "binary"}, 'o': {8, "octal"}}
+
+// scan_number scans decimal literals like 1_000, 2.5 and 6.02e23 and integers in other bases
+// prefixed with 0x, 0b or 0o. '_' can be used between digits to group them.
+// malformed literals are reported and still produce a Number so parsing goes on.
+func (s *Scanner) scan_number() {
+	s.addTokenWithLiteral(Number, s.number())
+}
+
+// number scans the rest of a number literal and returns its value, 0 when it's malformed
+func (s *Scanner) number() float64 {
+	if s.source[s.start] == '0' {
+		if radix, ok := radixes[unicode.ToLower(s.peek())]; ok {
 			s.advance()
+			return s.radix(radix.base, radix.name)
 		}
 	}
 
-	f, err := strconv.ParseFloat(s.source[s.start:s.current], 64)
+	s.digits(isDigit, true)
+	if s.peek() == '.' && isDigit(s.peekNext()) {
+		s.advance()
+		s.digits(isDigit, false)
+	}
+	if c := s.peek(); c == 'e' || c == 'E' {
+		s.advance()
+		if c := s.peek(); c == '+' || c == '-' {
+			s.advance()
+		}
+		if s.digits(isDigit, false) == 0 {
+			if !s.invalidSuffix("number") {
+				s.numberError(s.start, s.current, "exponent has no digits")
+			}
+			return 0
+		}
+	}
+	if s.invalidSuffix("number") {
+		return 0
+	}
+
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s.source[s.start:s.current], "_", ""), 64)
 	if err != nil {
-		s.error_reporter(s.line, err.Error())
+		s.numberError(s.start, s.current, "number literal out of range")
+		return 0
 	}
-	s.addTokenWithLiteral(Number, f)
+	return f
+}
+
+// radix scans the digits of an integer literal after its base prefix
+func (s *Scanner) radix(base int, name string) float64 {
+	valid := func(c rune) bool {
+		digit, err := strconv.ParseUint(string(c), base, 8)
+		return err == nil && int(digit) < base
+	}
+	count := s.digits(valid, false)
+	if s.invalidSuffix(name) {
+		return 0
+	}
+	if count == 0 {
+		s.numberError(s.start, s.current, name+" literal has no digits")
+		return 0
+	}
+
+	n, err := strconv.ParseUint(strings.ReplaceAll(s.source[s.start+2:s.current], "_", ""), base, 64)
+	if err != nil {
+		s.numberError(s.start, s.current, name+" literal out of range")
+		return 0
+	}
+	return float64(n)
+}
+
+// digits consumes a run of digits for which valid holds, possibly separated by single '_'s,
+// and returns how many digits it consumed. misplaced '_'s are reported and skipped.
+// afterDigit tells whether a digit was consumed right before.
+func (s *Scanner) digits(valid func(rune) bool, afterDigit bool) int {
+	count := 0
+	for {
+		c := s.peek()
+		if valid(c) {
+			s.advance()
+			count++
+			afterDigit = true
+			continue
+		}
+		if c != '_' {
+			return count
+		}
+		start := s.current
+		for s.peek() == '_' {
+			s.advance()
+		}
+		if !afterDigit || !valid(s.peek()) || s.current-start > 1 {
+			s.numberError(start, s.current, "'_' must separate digits")
+		}
+		afterDigit = false
+	}
+}
+
+// invalidSuffix reports letters or digits running into the end of a number literal, e.g. 0b102 or 12px.
+// it returns whether it found any, they are then consumed as part of the literal.
+func (s *Scanner) invalidSuffix(name string) bool {
+	if !isAlphaNumeric(s.peek()) {
+		return false
+	}
+	start := s.current
+	c := s.advance()
+	for isAlphaNumeric(s.peek()) {
+		s.advance()
+	}
+	s.numberError(start, start+utf8.RuneLen(c), fmt.Sprintf("invalid digit '%c' in %s literal", c, name))
+	return true
+}
+
+// numberError reports a malformed number literal at the source between start and end
+func (s *Scanner) numberError(start, end int, message string) {
+	// number literals are ascii up to the first invalid character, so offsets match columns
+	span := diagnostics.Span{Line: s.startLine, Column: s.startColumn + start - s.start, Start: start, End: end}
+	s.reporter.Report(diagnostics.Error(diagnostics.InvalidNumber, span, message))
 }
 
 func (s *Scanner) scan_identifier() {
@@ -174,14 +404,14 @@ func (s *Scanner) scan_identifier() {
 
 func (s *Scanner) scan_multiline_comment() {
 	for !s.isAtEnd() && !(s.peek() == '*' && s.peekNext() == '/') {
-		if s.peek() == '\n' {
-			s.line++
+		if s.advance() == '\n' {
+			s.newline()
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
-		s.error_reporter(s.line, "unterminated multiline comment")
+		s.reporter.Report(diagnostics.Error(diagnostics.UnterminatedComment, s.span(), "unterminated multiline comment").
+			WithSuggestion("close the comment with '*/'"))
 		return
 	}
 
@@ -190,18 +420,31 @@ func (s *Scanner) scan_multiline_comment() {
 	s.advance()
 }
 
+// number literals only use ascii digits, other decimal digits can still appear inside identifiers
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// identifiers follow the shape of Unicode's default identifiers (UAX #31):
+// they start with a letter, a letter number or '_' and continue with those,
+// decimal digits, combining marks and connector punctuation
+func isIdentifierStart(c rune) bool {
+	return c == '_' || unicode.In(c, unicode.Letter, unicode.Nl)
+}
+
 func isAlphaNumeric(c rune) bool {
-	return c == '_' || unicode.IsDigit(c) || unicode.IsLetter(c)
+	return isIdentifierStart(c) || unicode.In(c, unicode.Nd, unicode.Mn, unicode.Mc, unicode.Pc)
 }
 
 func (s *Scanner) match(expected rune) bool {
 	if s.isAtEnd() {
 		return false
 	}
-	if rune(s.source[s.current]) != expected {
+	c, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if c != expected {
 		return false
 	}
-	s.current++
+	s.current += size
 	return true
 }
 
@@ -209,9 +452,10 @@ func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
+// advance consumes the next rune, invalid UTF-8 is consumed a byte at a time as utf8.RuneError
 func (s *Scanner) advance() rune {
-	c := rune(s.source[s.current])
-	s.current++
+	c, size := utf8.DecodeRuneInString(s.source[s.current:])
+	s.current += size
 	return c
 }
 
@@ -219,20 +463,56 @@ func (s *Scanner) peek() rune {
 	if s.isAtEnd() {
 		return rune(0)
 	}
-	return rune(s.source[s.current])
+	c, _ := utf8.DecodeRuneInString(s.source[s.current:])
+	return c
 }
 
 func (s *Scanner) peekNext() rune {
-	if s.current+1 >= len(s.source) {
+	if s.isAtEnd() {
+		return rune(0)
+	}
+	_, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if s.current+size >= len(s.source) {
 		return rune(0)
 	}
-	return rune(s.source[s.current+1])
+	c, _ := utf8.DecodeRuneInString(s.source[s.current+size:])
+	return c
+}
+
+// newline must be called right after consuming a '\n'
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+	s.lineColumns, s.lineColumnsAt = 1, s.current
+}
+
+// column is 1-based and counted in runes from the start of the line
+func (s *Scanner) column() int {
+	if s.current < s.lineColumnsAt {
+		s.lineColumns, s.lineColumnsAt = 1, s.lineStart
+	}
+	s.lineColumns += utf8.RuneCountInString(s.source[s.lineColumnsAt:s.current])
+	s.lineColumnsAt = s.current
+	return s.lineColumns
+}
+
+// span covers the token being scanned so far
+func (s *Scanner) span() diagnostics.Span {
+	return diagnostics.Span{Line: s.startLine, Column: s.startColumn, Start: s.start, End: s.current}
 }
 
 func (s *Scanner) addToken(tokenType TokenType) {
-	s.tokens = append(s.tokens, Token{Type: tokenType, Lexeme: s.source[s.start:s.current], Line: s.line})
+	s.addTokenWithLiteral(tokenType, nil)
 }
 
 func (s *Scanner) addTokenWithLiteral(tokenType TokenType, literal interface{}) {
-	s.tokens = append(s.tokens, Token{Type: tokenType, Lexeme: s.source[s.start:s.current], Literal: literal, Line: s.line})
+	s.tokens = append(s.tokens, Token{
+		Type:    tokenType,
+		Lexeme:  s.source[s.start:s.current],
+		Literal: literal,
+		Line:    s.startLine,
+		Column:  s.startColumn,
+		Start:   s.start,
+		End:     s.current,
+	})
 }
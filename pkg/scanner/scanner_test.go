@@ -2,6 +2,8 @@ package scanner
 
 import (
 	"testing"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
 )
 
 func TestMatch(t *testing.T) {
@@ -18,7 +20,7 @@ func TestMatch(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			scanner := NewScanner(tt.input, func(i int, s string) {})
+			scanner := NewScanner(tt.input, &diagnostics.Collector{})
 			scanner.current = tt.current
 			got := scanner.match(tt.match)
 			if got != tt.expected {
@@ -51,71 +53,315 @@ func TestScanTokens(t *testing.T) {
 		input    string
 		expected []Token
 	}{
-		{"", []Token{{EOF, "", nil, 1}}},
+		{"", []Token{{Type: EOF, Lexeme: "", Line: 1}}},
 		{" \n {}", []Token{
-			{LEFT_BRACE, "{", nil, 2},
-			{RIGHT_BRACE, "}", nil, 2},
-			{EOF, "", nil, 2}},
+			{Type: LEFT_BRACE, Lexeme: "{", Line: 2},
+			{Type: RIGHT_BRACE, Lexeme: "}", Line: 2},
+			{Type: EOF, Lexeme: "", Line: 2}},
 		},
-		{"// This is a comment\n\n", []Token{{EOF, "", nil, 3}}},
+		{"// This is a comment\n\n", []Token{{Type: EOF, Lexeme: "", Line: 3}}},
 		{"\"hello\" // \"dkndknjd ndjjnkj\" \n (\"world\") ", []Token{
-			{STRING, "\"hello\"", "hello", 1},
-			{LEFT_PAREN, "(", nil, 2},
-			{STRING, "\"world\"", "world", 2},
-			{RIGHT_PAREN, ")", nil, 2},
-			{EOF, "", nil, 2}},
+			{Type: STRING, Lexeme: "\"hello\"", Literal: "hello", Line: 1},
+			{Type: LEFT_PAREN, Lexeme: "(", Line: 2},
+			{Type: STRING, Lexeme: "\"world\"", Literal: "world", Line: 2},
+			{Type: RIGHT_PAREN, Lexeme: ")", Line: 2},
+			{Type: EOF, Lexeme: "", Line: 2}},
 		},
-		{"123.22.", []Token{{Number, "123.22", float64(123.22), 1}, {DOT, ".", nil, 1}, {EOF, "", nil, 1}}},
+		{"123.22.", []Token{{Type: Number, Lexeme: "123.22", Literal: float64(123.22), Line: 1}, {Type: DOT, Lexeme: ".", Line: 1}, {Type: EOF, Lexeme: "", Line: 1}}},
 		{"orange = 0\n while (orange <= 3) {\n if (orange % 2 == 0) {\n print(orange)\n orange = orange  + 1 \n} ", []Token{
-			{IDENTIFIER, "orange", nil, 1},
-			{EQUAL, "=", nil, 1},
-			{Number, "0", float64(0), 1},
-			{WHILE, "while", nil, 2},
-			{LEFT_PAREN, "(", nil, 2},
-			{IDENTIFIER, "orange", nil, 2},
-			{LESS_EQUAL, "<=", nil, 2},
-			{Number, "3", float64(3), 2},
-			{RIGHT_PAREN, ")", nil, 2},
-			{LEFT_BRACE, "{", nil, 2},
-			{IF, "if", nil, 3},
-			{LEFT_PAREN, "(", nil, 3},
-			{IDENTIFIER, "orange", nil, 3},
-			{MODULO, "%", nil, 3},
-			{Number, "2", float64(2), 3},
-			{EQUAL_EQUAL, "==", nil, 3},
-			{Number, "0", float64(0), 3},
-			{RIGHT_PAREN, ")", nil, 3},
-			{LEFT_BRACE, "{", nil, 3},
-			{PRINT, "print", nil, 4},
-			{LEFT_PAREN, "(", nil, 4},
-			{IDENTIFIER, "orange", nil, 4},
-			{RIGHT_PAREN, ")", nil, 4},
-			{IDENTIFIER, "orange", nil, 5},
-			{EQUAL, "=", nil, 5},
-			{IDENTIFIER, "orange", nil, 5},
-			{PLUS, "+", nil, 5},
-			{Number, "1", float64(1), 5},
-			{RIGHT_BRACE, "}", nil, 6},
-			{EOF, "", nil, 6},
+			{Type: IDENTIFIER, Lexeme: "orange", Line: 1},
+			{Type: EQUAL, Lexeme: "=", Line: 1},
+			{Type: Number, Lexeme: "0", Literal: float64(0), Line: 1},
+			{Type: WHILE, Lexeme: "while", Line: 2},
+			{Type: LEFT_PAREN, Lexeme: "(", Line: 2},
+			{Type: IDENTIFIER, Lexeme: "orange", Line: 2},
+			{Type: LESS_EQUAL, Lexeme: "<=", Line: 2},
+			{Type: Number, Lexeme: "3", Literal: float64(3), Line: 2},
+			{Type: RIGHT_PAREN, Lexeme: ")", Line: 2},
+			{Type: LEFT_BRACE, Lexeme: "{", Line: 2},
+			{Type: IF, Lexeme: "if", Line: 3},
+			{Type: LEFT_PAREN, Lexeme: "(", Line: 3},
+			{Type: IDENTIFIER, Lexeme: "orange", Line: 3},
+			{Type: MODULO, Lexeme: "%", Line: 3},
+			{Type: Number, Lexeme: "2", Literal: float64(2), Line: 3},
+			{Type: EQUAL_EQUAL, Lexeme: "==", Line: 3},
+			{Type: Number, Lexeme: "0", Literal: float64(0), Line: 3},
+			{Type: RIGHT_PAREN, Lexeme: ")", Line: 3},
+			{Type: LEFT_BRACE, Lexeme: "{", Line: 3},
+			{Type: PRINT, Lexeme: "print", Line: 4},
+			{Type: LEFT_PAREN, Lexeme: "(", Line: 4},
+			{Type: IDENTIFIER, Lexeme: "orange", Line: 4},
+			{Type: RIGHT_PAREN, Lexeme: ")", Line: 4},
+			{Type: IDENTIFIER, Lexeme: "orange", Line: 5},
+			{Type: EQUAL, Lexeme: "=", Line: 5},
+			{Type: IDENTIFIER, Lexeme: "orange", Line: 5},
+			{Type: PLUS, Lexeme: "+", Line: 5},
+			{Type: Number, Lexeme: "1", Literal: float64(1), Line: 5},
+			{Type: RIGHT_BRACE, Lexeme: "}", Line: 6},
+			{Type: EOF, Lexeme: "", Line: 6},
 		}},
-		{"/*dkmdknde\ndjwndjkwn\nkwjhdkjw*/", []Token{{EOF, "", nil, 3}}},
-		{" 3 --", []Token{{Number, "3", float64(3), 1}, {DECREMENT, "--", nil, 1}, {EOF, "", nil, 1}}},
+		{"/*dkmdknde\ndjwndjkwn\nkwjhdkjw*/", []Token{{Type: EOF, Lexeme: "", Line: 3}}},
+		{"xs[0]", []Token{
+			{Type: IDENTIFIER, Lexeme: "xs", Line: 1},
+			{Type: LEFT_BRACKET, Lexeme: "[", Line: 1},
+			{Type: Number, Lexeme: "0", Literal: float64(0), Line: 1},
+			{Type: RIGHT_BRACKET, Lexeme: "]", Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		{"() => x", []Token{
+			{Type: LEFT_PAREN, Lexeme: "(", Line: 1},
+			{Type: RIGHT_PAREN, Lexeme: ")", Line: 1},
+			{Type: ARROW, Lexeme: "=>", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "x", Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		{"f(...xs).. .", []Token{
+			{Type: IDENTIFIER, Lexeme: "f", Line: 1},
+			{Type: LEFT_PAREN, Lexeme: "(", Line: 1},
+			{Type: ELLIPSIS, Lexeme: "...", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "xs", Line: 1},
+			{Type: RIGHT_PAREN, Lexeme: ")", Line: 1},
+			{Type: DOT, Lexeme: ".", Line: 1},
+			{Type: DOT, Lexeme: ".", Line: 1},
+			{Type: DOT, Lexeme: ".", Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		{"a += 1 -= 2 *= 3 /= 4 %= 5 / 6", []Token{
+			{Type: IDENTIFIER, Lexeme: "a", Line: 1},
+			{Type: PLUS_EQUAL, Lexeme: "+=", Line: 1},
+			{Type: Number, Lexeme: "1", Literal: float64(1), Line: 1},
+			{Type: MINUS_EQUAL, Lexeme: "-=", Line: 1},
+			{Type: Number, Lexeme: "2", Literal: float64(2), Line: 1},
+			{Type: STAR_EQUAL, Lexeme: "*=", Line: 1},
+			{Type: Number, Lexeme: "3", Literal: float64(3), Line: 1},
+			{Type: SLASH_EQUAL, Lexeme: "/=", Line: 1},
+			{Type: Number, Lexeme: "4", Literal: float64(4), Line: 1},
+			{Type: MODULO_EQUAL, Lexeme: "%=", Line: 1},
+			{Type: Number, Lexeme: "5", Literal: float64(5), Line: 1},
+			{Type: SLASH, Lexeme: "/", Line: 1},
+			{Type: Number, Lexeme: "6", Literal: float64(6), Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		{" 3 --", []Token{{Type: Number, Lexeme: "3", Literal: float64(3), Line: 1}, {Type: DECREMENT, Lexeme: "--", Line: 1}, {Type: EOF, Lexeme: "", Line: 1}}},
 
 		//malformed multiline comment
-		{"/*dkmdknde\ndjwndjkwn\nkwjhdkjw", []Token{{EOF, "", nil, 3}}},
+		{"/*dkmdknde\ndjwndjkwn\nkwjhdkjw", []Token{{Type: EOF, Lexeme: "", Line: 3}}},
 		//malformed multiline comment
-		{"/*dkmdknde\ndjwndjkwn\nkwjhdkjw*", []Token{{EOF, "", nil, 3}}},
+		{"/*dkmdknde\ndjwndjkwn\nkwjhdkjw*", []Token{{Type: EOF, Lexeme: "", Line: 3}}},
 		// unknown character
-		{"3 ^ 4", []Token{{Number, "3", float64(3), 1}, {Number, "4", float64(4), 1}, {EOF, "", nil, 1}}},
+		{"3 ^ 4", []Token{{Type: Number, Lexeme: "3", Literal: float64(3), Line: 1}, {Type: Number, Lexeme: "4", Literal: float64(4), Line: 1}, {Type: EOF, Lexeme: "", Line: 1}}},
+		{`"a\tb\n\"c\" \\ \$ \u{e9}\u{1F600}"`, []Token{{Type: STRING, Lexeme: `"a\tb\n\"c\" \\ \$ \u{e9}\u{1F600}"`, Literal: "a\tb\n\"c\" \\ $ é😀", Line: 1}, {Type: EOF, Lexeme: "", Line: 1}}},
+		{`"hi ${name}, ${ {1: 2}[1] }!"`, []Token{
+			{Type: INTERPOLATION, Lexeme: `"hi ${`, Literal: "hi ", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "name", Line: 1},
+			{Type: INTERPOLATION, Lexeme: "}, ${", Literal: ", ", Line: 1},
+			{Type: LEFT_BRACE, Lexeme: "{", Line: 1},
+			{Type: Number, Lexeme: "1", Literal: float64(1), Line: 1},
+			{Type: COLON, Lexeme: ":", Line: 1},
+			{Type: Number, Lexeme: "2", Literal: float64(2), Line: 1},
+			{Type: RIGHT_BRACE, Lexeme: "}", Line: 1},
+			{Type: LEFT_BRACKET, Lexeme: "[", Line: 1},
+			{Type: Number, Lexeme: "1", Literal: float64(1), Line: 1},
+			{Type: RIGHT_BRACKET, Lexeme: "]", Line: 1},
+			{Type: STRING, Lexeme: `}!"`, Literal: "!", Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		{"var café = \"naïve 日本\";", []Token{
+			{Type: VAR, Lexeme: "var", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "café", Line: 1},
+			{Type: EQUAL, Lexeme: "=", Line: 1},
+			{Type: STRING, Lexeme: "\"naïve 日本\"", Literal: "naïve 日本", Line: 1},
+			{Type: SEMICOLON, Lexeme: ";", Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		// identifiers can start with '_' and contain digits, combining marks and other scripts
+		{"_x1 日本語 x̃ π٣ Ⅻ", []Token{
+			{Type: IDENTIFIER, Lexeme: "_x1", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "日本語", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "x̃", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "π٣", Line: 1},
+			{Type: IDENTIFIER, Lexeme: "Ⅻ", Line: 1},
+			{Type: EOF, Lexeme: "", Line: 1}},
+		},
+		// only ascii digits start numbers, symbols aren't identifiers
+		{"٣ → 3", []Token{{Type: Number, Lexeme: "3", Literal: float64(3), Line: 1}, {Type: EOF, Lexeme: "", Line: 1}}},
 		//unterminated string
-		{"\"hello 4 * 2", []Token{{EOF, "", nil, 1}}},
+		{"\"hello 4 * 2", []Token{{Type: EOF, Lexeme: "", Line: 1}}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			scanner := NewScanner(tt.input, func(i int, s string) {})
+			scanner := NewScanner(tt.input, &diagnostics.Collector{})
 			got := scanner.ScanTokens()
 			compare(tt.input, got, tt.expected, t)
 		})
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "var x = 10;\n  print \"a\nb\" + x;"
+	expected := []diagnostics.Span{
+		{Line: 1, Column: 1, Start: 0, End: 3},
+		{Line: 1, Column: 5, Start: 4, End: 5},
+		{Line: 1, Column: 7, Start: 6, End: 7},
+		{Line: 1, Column: 9, Start: 8, End: 10},
+		{Line: 1, Column: 11, Start: 10, End: 11},
+		{Line: 2, Column: 3, Start: 14, End: 19},
+		// multi line strings are located where they start
+		{Line: 2, Column: 9, Start: 20, End: 25},
+		{Line: 3, Column: 4, Start: 26, End: 27},
+		{Line: 3, Column: 6, Start: 28, End: 29},
+		{Line: 3, Column: 7, Start: 29, End: 30},
+		{Line: 3, Column: 8, Start: 30, End: 30},
+	}
+	tokens := NewScanner(input, &diagnostics.Collector{}).ScanTokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("ScanTokens(%q) returned %d tokens, want %d", input, len(tokens), len(expected))
+	}
+	for i, token := range tokens {
+		if token.Span() != expected[i] {
+			t.Errorf("ScanTokens(%q)[%d] %s span = %+v, want %+v", input, i, token.Lexeme, token.Span(), expected[i])
+		}
+		if input[token.Start:token.End] != token.Lexeme {
+			t.Errorf("ScanTokens(%q)[%d] offsets point to %q, want %q", input, i, input[token.Start:token.End], token.Lexeme)
+		}
+	}
+}
+
+func TestUnicodePositions(t *testing.T) {
+	input := "var é = \"ü\";\n  π = é;"
+	expected := []diagnostics.Span{
+		{Line: 1, Column: 1, Start: 0, End: 3},
+		{Line: 1, Column: 5, Start: 4, End: 6},
+		{Line: 1, Column: 7, Start: 7, End: 8},
+		{Line: 1, Column: 9, Start: 9, End: 13},
+		{Line: 1, Column: 12, Start: 13, End: 14},
+		{Line: 2, Column: 3, Start: 17, End: 19},
+		{Line: 2, Column: 5, Start: 20, End: 21},
+		{Line: 2, Column: 7, Start: 22, End: 24},
+		{Line: 2, Column: 8, Start: 24, End: 25},
+		{Line: 2, Column: 9, Start: 25, End: 25},
+	}
+	tokens := NewScanner(input, &diagnostics.Collector{}).ScanTokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("ScanTokens(%q) returned %d tokens, want %d", input, len(tokens), len(expected))
+	}
+	for i, token := range tokens {
+		if token.Span() != expected[i] {
+			t.Errorf("ScanTokens(%q)[%d] %s span = %+v, want %+v", input, i, token.Lexeme, token.Span(), expected[i])
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostics.Span
+	}{
+		{"1 ^ 2", diagnostics.Span{Line: 1, Column: 3, Start: 2, End: 3}},
+		{"x\n  \"open", diagnostics.Span{Line: 2, Column: 3, Start: 4, End: 9}},
+		// invalid escapes are reported on their own, not on the whole string
+		{`"ok \q"`, diagnostics.Span{Line: 1, Column: 5, Start: 4, End: 6}},
+		{`"\u{D800}"`, diagnostics.Span{Line: 1, Column: 2, Start: 1, End: 9}},
+		{`"\u{}"`, diagnostics.Span{Line: 1, Column: 2, Start: 1, End: 5}},
+		{`"${1"`, diagnostics.Span{Line: 1, Column: 5, Start: 4, End: 5}},
+		// columns count runes, offsets count bytes
+		{"\"日本\" ^", diagnostics.Span{Line: 1, Column: 6, Start: 9, End: 10}},
+		{"ñ\n é €", diagnostics.Span{Line: 2, Column: 4, Start: 7, End: 10}},
+		{"a \xff", diagnostics.Span{Line: 1, Column: 3, Start: 2, End: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var collector diagnostics.Collector
+			NewScanner(tt.input, &collector).ScanTokens()
+			if got := collector.Diagnostics()[0].Span; got != tt.expected {
+				t.Errorf("ScanTokens(%q) reported error at %+v, want %+v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"0", 0},
+		{"42", 42},
+		{"3.25", 3.25},
+		{"1_000_000", 1000000},
+		{"1_0.5_0", 10.5},
+		{"0xFF", 255},
+		{"0Xff_ff", 65535},
+		{"0b1010", 10},
+		{"0B1_0", 2},
+		{"0o17", 15},
+		{"1e3", 1000},
+		{"1E+2", 100},
+		{"1e-9", 1e-9},
+		{"6.02e23", 6.02e23},
+		{"2.5e1_0", 2.5e10},
+		{"007", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var collector diagnostics.Collector
+			tokens := NewScanner(tt.input, &collector).ScanTokens()
+			if collector.HasErrors() {
+				t.Fatalf("ScanTokens(%q) reported %s", tt.input, collector.Diagnostics()[0].Message)
+			}
+			if len(tokens) != 2 || tokens[0].Type != Number || tokens[0].Lexeme != tt.input {
+				t.Fatalf("ScanTokens(%q) = %v, want a single number", tt.input, tokens)
+			}
+			if tokens[0].Literal != tt.expected {
+				t.Errorf("ScanTokens(%q) = %v, want %v", tt.input, tokens[0].Literal, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		span    diagnostics.Span
+	}{
+		{"x = 0x;", "hexadecimal literal has no digits", diagnostics.Span{Line: 1, Column: 5, Start: 4, End: 6}},
+		{"0b", "binary literal has no digits", diagnostics.Span{Line: 1, Column: 1, Start: 0, End: 2}},
+		{"0b102", "invalid digit '2' in binary literal", diagnostics.Span{Line: 1, Column: 5, Start: 4, End: 5}},
+		{"0o78", "invalid digit '8' in octal literal", diagnostics.Span{Line: 1, Column: 4, Start: 3, End: 4}},
+		{"0xFG", "invalid digit 'G' in hexadecimal literal", diagnostics.Span{Line: 1, Column: 4, Start: 3, End: 4}},
+		{"1e", "exponent has no digits", diagnostics.Span{Line: 1, Column: 1, Start: 0, End: 2}},
+		{"1e+;", "exponent has no digits", diagnostics.Span{Line: 1, Column: 1, Start: 0, End: 3}},
+		{"12px", "invalid digit 'p' in number literal", diagnostics.Span{Line: 1, Column: 3, Start: 2, End: 3}},
+		{"1__000", "'_' must separate digits", diagnostics.Span{Line: 1, Column: 2, Start: 1, End: 3}},
+		{"1_", "'_' must separate digits", diagnostics.Span{Line: 1, Column: 2, Start: 1, End: 2}},
+		{"0x_1", "'_' must separate digits", diagnostics.Span{Line: 1, Column: 3, Start: 2, End: 3}},
+		{"1_.5", "'_' must separate digits", diagnostics.Span{Line: 1, Column: 2, Start: 1, End: 2}},
+		{"0x1_0000_0000_0000_0000", "hexadecimal literal out of range", diagnostics.Span{Line: 1, Column: 1, Start: 0, End: 23}},
+		{"1e400", "number literal out of range", diagnostics.Span{Line: 1, Column: 1, Start: 0, End: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var collector diagnostics.Collector
+			tokens := NewScanner(tt.input, &collector).ScanTokens()
+			if !collector.HasErrors() {
+				t.Fatalf("ScanTokens(%q) reported no errors", tt.input)
+			}
+			d := collector.Diagnostics()[0]
+			if d.Code != diagnostics.InvalidNumber || d.Message != tt.message || d.Span != tt.span {
+				t.Errorf("ScanTokens(%q) reported %s %q at %+v, want %q at %+v", tt.input, d.Code, d.Message, d.Span, tt.message, tt.span)
+			}
+			// the literal still becomes a single number token so the parser doesn't report it again
+			numbers := 0
+			for _, token := range tokens {
+				if token.Type == Number {
+					numbers++
+				}
+			}
+			if numbers != 1 {
+				t.Errorf("ScanTokens(%q) = %v, want a single number", tt.input, tokens)
+			}
+		})
+	}
+}
@@ -1,12 +1,23 @@
 package scanner
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/fadyZohdy/gLox/pkg/diagnostics"
+)
 
 type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal interface{}
 	Line    int
+	// Column is 1-based and counted in runes, Start and End are the byte offsets of the lexeme in the source
+	Column     int
+	Start, End int
+}
+
+func (t Token) Span() diagnostics.Span {
+	return diagnostics.Span{Line: t.Line, Column: t.Column, Start: t.Start, End: t.End}
 }
 
 func (t Token) String() string {
@@ -7,8 +7,11 @@ const (
 	RIGHT_PAREN   = "RIGHT_PAREN"
 	LEFT_BRACE    = "LEFT_BRACE"
 	RIGHT_BRACE   = "RIGHT_BRACE"
+	LEFT_BRACKET  = "LEFT_BRACKET"
+	RIGHT_BRACKET = "RIGHT_BRACKET"
 	COMMA         = "COMMA"
 	DOT           = "DOT"
+	ELLIPSIS      = "ELLIPSIS"
 	MINUS         = "MINUS"
 	PLUS          = "PLUS"
 	SEMICOLON     = "SEMICOLON"
@@ -29,49 +32,72 @@ const (
 	GREATER_EQUAL = "GREATER_EQUAL"
 	LESS          = "LESS"
 	LESS_EQUAL    = "LESS_EQUAL"
+	ARROW         = "ARROW"
+	PLUS_EQUAL    = "PLUS_EQUAL"
+	MINUS_EQUAL   = "MINUS_EQUAL"
+	STAR_EQUAL    = "STAR_EQUAL"
+	SLASH_EQUAL   = "SLASH_EQUAL"
+	MODULO_EQUAL  = "MODULO_EQUAL"
 
 	IDENTIFIER = "IDENTIFIER"
 	STRING     = "STRING"
-	Number     = "Number"
+	// INTERPOLATION is the part of a string literal before a "${", the last part is a STRING
+	INTERPOLATION = "INTERPOLATION"
+	Number        = "Number"
 
-	AND    = "AND"
-	BREAK  = "BREAK"
-	CLASS  = "CLASS"
-	ELSE   = "ELSE"
-	FALSE  = "FALSE"
-	FUN    = "FUN"
-	FOR    = "FOR"
-	IF     = "IF"
-	NIL    = "NIL"
-	OR     = "OR"
-	PRINT  = "PRINT"
-	RETURN = "RETURN"
-	STATIC = "STATIC"
-	SUPER  = "SUPER"
-	THIS   = "THIS"
-	TRUE   = "TRUE"
-	VAR    = "VAR"
-	WHILE  = "WHILE"
+	AND      = "AND"
+	BREAK    = "BREAK"
+	CATCH    = "CATCH"
+	CLASS    = "CLASS"
+	CONST    = "CONST"
+	CONTINUE = "CONTINUE"
+	ELSE     = "ELSE"
+	FALSE    = "FALSE"
+	FINALLY  = "FINALLY"
+	FUN      = "FUN"
+	FOR      = "FOR"
+	IF       = "IF"
+	IMPORT   = "IMPORT"
+	NIL      = "NIL"
+	OR       = "OR"
+	PRINT    = "PRINT"
+	RETURN   = "RETURN"
+	STATIC   = "STATIC"
+	SUPER    = "SUPER"
+	THIS     = "THIS"
+	THROW    = "THROW"
+	TRUE     = "TRUE"
+	TRY      = "TRY"
+	VAR      = "VAR"
+	WHILE    = "WHILE"
 
 	EOF = "EOF"
 )
 
 var Keywords = map[string]TokenType{
-	"and":    AND,
-	"break":  BREAK,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
+	"and":      AND,
+	"break":    BREAK,
+	"catch":    CATCH,
+	"class":    CLASS,
+	"const":    CONST,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"finally":  FINALLY,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"import":   IMPORT,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"static":   STATIC,
+	"super":    SUPER,
+	"this":     THIS,
+	"throw":    THROW,
+	"true":     TRUE,
+	"try":      TRY,
+	"var":      VAR,
+	"while":    WHILE,
 }